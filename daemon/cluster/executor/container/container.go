@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -60,6 +61,10 @@ func (c *containerConfig) setTask(t *api.Task, node *api.NodeDescription) error
 		if err := validateMounts(ctr.Mounts); err != nil {
 			return err
 		}
+
+		if err := validateDevices(ctr.Devices, node); err != nil {
+			return err
+		}
 	}
 
 	// index the networks by name
@@ -90,6 +95,29 @@ func (c *containerConfig) setTask(t *api.Task, node *api.NodeDescription) error
 	return nil
 }
 
+// validateDevices rejects a task's device requests up front if the node
+// it has been assigned to doesn't advertise the device, so a scheduling
+// mistake (or a node losing a device after assignment) surfaces as a task
+// failure here rather than as a confusing container-create error later.
+func validateDevices(devices []*api.ContainerSpec_Device, node *api.NodeDescription) error {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	available := make(map[string]bool, len(node.GetDevices()))
+	for _, d := range node.GetDevices() {
+		available[d.PathOnHost] = true
+	}
+
+	for _, d := range devices {
+		if !available[d.PathOnHost] {
+			return fmt.Errorf("device %q is not available on this node", d.PathOnHost)
+		}
+	}
+
+	return nil
+}
+
 func (c *containerConfig) networkAttachmentContainerID() string {
 	attachment := c.task.Spec.GetAttachment()
 	if attachment == nil {
@@ -115,6 +143,35 @@ func (c *containerConfig) nameOrID() string {
 	return c.networkAttachmentContainerID()
 }
 
+// taskGroup returns the task's TaskGroup spec, or nil if the task isn't
+// part of a group. A group is a set of tasks scheduled together on one
+// node that share selected Linux namespaces, borrowed from podman's pod
+// concept: one task in the group is the "infra" task, holding the shared
+// namespaces, and the rest are peers that join them.
+func (c *containerConfig) taskGroup() *api.TaskGroupSpec {
+	return c.task.Spec.TaskGroup
+}
+
+// isGroupInfra reports whether this task is the infra task of its group,
+// i.e. the one other peers' namespaces attach to, rather than a peer
+// joining another task's namespaces.
+func (c *containerConfig) isGroupInfra() bool {
+	tg := c.taskGroup()
+	return tg != nil && tg.IsInfra
+}
+
+// groupNameOrID is nameOrID's group-scoped counterpart: callers that need
+// to address the set of containers making up a task's group (rather than
+// this task's own container) use the group ID instead of the task's own
+// name/ID.
+func (c *containerConfig) groupNameOrID() string {
+	tg := c.taskGroup()
+	if tg == nil {
+		return c.nameOrID()
+	}
+	return tg.GroupID
+}
+
 func (c *containerConfig) name() string {
 	if c.task.Annotations.Name != "" {
 		// if set, use the container Annotations.Name field, set in the orchestrator.
@@ -398,19 +455,61 @@ func (c *containerConfig) healthcheck() *container.HealthConfig {
 	}
 }
 
-func (c *containerConfig) hostConfig(deps exec.VolumeGetter) *container.HostConfig {
+// imageSeccompLabel is an image label that names a Config, bound to the
+// service, holding a seccomp profile to apply to tasks running that image
+// when the service spec itself leaves Seccomp unset. This lets an image
+// author ship a policy tuned to what the image actually needs without
+// every service spec that runs it having to repeat it - but since the
+// label ships in the image rather than the service spec, it can only name
+// a Config the operator has explicitly bound, not set confinement
+// directly; see applyPrivileges.
+const imageSeccompLabel = "com.docker.seccomp.profile"
+
+// groupNamespaceModes translates the task's shared-namespace list, plus the
+// infra task's already-running container ID, into the container:<id> mode
+// strings that tell the engine which namespaces to join rather than
+// create. The infra task itself never joins anything (there's nothing to
+// join yet), so it always gets an empty result.
+func (c *containerConfig) groupNamespaceModes(infraContainerID string) (netMode, ipcMode, pidMode, utsMode string) {
+	tg := c.taskGroup()
+	if tg == nil || tg.IsInfra || infraContainerID == "" {
+		return "", "", "", ""
+	}
+
+	joined := "container:" + infraContainerID
+	for _, ns := range tg.SharedNamespaces {
+		switch ns {
+		case api.TaskGroupSpec_NETWORK:
+			netMode = joined
+		case api.TaskGroupSpec_IPC:
+			ipcMode = joined
+		case api.TaskGroupSpec_PID:
+			pidMode = joined
+		case api.TaskGroupSpec_UTS:
+			utsMode = joined
+		}
+	}
+	return netMode, ipcMode, pidMode, utsMode
+}
+
+// hostConfig builds the HostConfig for this task's container. infraContainerID
+// is the container ID of this task's group's infra task, if any; it must
+// already be running, since container:<id> namespace modes require the
+// target container to exist. Callers outside a group pass "".
+func (c *containerConfig) hostConfig(deps exec.VolumeGetter, imageLabels map[string]string, b executorpkg.Backend, infraContainerID string) (*container.HostConfig, error) {
 	hc := &container.HostConfig{
-		Resources:      c.resources(),
-		GroupAdd:       c.spec().Groups,
-		PortBindings:   c.portBindings(),
-		Mounts:         c.mounts(deps),
-		ReadonlyRootfs: c.spec().ReadOnly,
-		Isolation:      c.isolation(),
-		Init:           c.init(),
-		Sysctls:        c.spec().Sysctls,
-		CapAdd:         c.spec().CapabilityAdd,
-		CapDrop:        c.spec().CapabilityDrop,
-		OomScoreAdj:    int(c.spec().OomScoreAdj),
+		Resources:         c.resources(b),
+		GroupAdd:          c.spec().Groups,
+		PortBindings:      c.portBindings(),
+		Mounts:            c.mounts(deps),
+		ReadonlyRootfs:    c.spec().ReadOnly,
+		Isolation:         c.isolation(),
+		Init:              c.init(),
+		Sysctls:           c.spec().Sysctls,
+		CapAdd:            c.spec().CapabilityAdd,
+		CapDrop:           c.spec().CapabilityDrop,
+		OomScoreAdj:       int(c.spec().OomScoreAdj),
+		DeviceCgroupRules: c.deviceCgroupRules(),
 	}
 
 	if c.spec().DNSConfig != nil {
@@ -419,22 +518,12 @@ func (c *containerConfig) hostConfig(deps exec.VolumeGetter) *container.HostConf
 		hc.DNSOptions = c.spec().DNSConfig.Options
 	}
 
-	c.applyPrivileges(hc)
-
-	// The format of extra hosts on swarmkit is specified in:
-	// http://man7.org/linux/man-pages/man5/hosts.5.html
-	//    IP_address canonical_hostname [aliases...]
-	// However, the format of ExtraHosts in HostConfig is
-	//    <host>:<ip>
-	// We need to do the conversion here
-	// (Alias is ignored for now)
-	for _, entry := range c.spec().Hosts {
-		parts := strings.Fields(entry)
-		if len(parts) > 1 {
-			hc.ExtraHosts = append(hc.ExtraHosts, fmt.Sprintf("%s:%s", parts[1], parts[0]))
-		}
+	if err := c.applyPrivileges(hc, imageLabels); err != nil {
+		return nil, err
 	}
 
+	hc.ExtraHosts = hostsToExtraHosts(c.spec().Hosts)
+
 	if c.task.LogDriver != nil {
 		hc.LogConfig = container.LogConfig{
 			Type:   c.task.LogDriver.Name,
@@ -450,7 +539,52 @@ func (c *containerConfig) hostConfig(deps exec.VolumeGetter) *container.HostConf
 		}
 	}
 
-	return hc
+	// A group peer joins its infra task's namespaces instead of creating
+	// its own; this takes precedence over the predefined-network mode set
+	// above, since joining the infra task's network namespace implies
+	// joining whatever network it's attached to as well.
+	if netMode, ipcMode, pidMode, utsMode := c.groupNamespaceModes(infraContainerID); netMode != "" || ipcMode != "" || pidMode != "" || utsMode != "" {
+		if netMode != "" {
+			hc.NetworkMode = container.NetworkMode(netMode)
+		}
+		if ipcMode != "" {
+			hc.IpcMode = container.IpcMode(ipcMode)
+		}
+		if pidMode != "" {
+			hc.PidMode = container.PidMode(pidMode)
+		}
+		if utsMode != "" {
+			hc.UTSMode = container.UTSMode(utsMode)
+		}
+	}
+
+	return hc, nil
+}
+
+// hostsToExtraHosts converts hosts entries in the format swarmkit specifies,
+// per http://man7.org/linux/man-pages/man5/hosts.5.html:
+//
+//	IP_address canonical_hostname [aliases...]
+//
+// into the <host>:<ip> format container.HostConfig.ExtraHosts expects,
+// emitting one entry per alias in addition to the canonical hostname.
+// fields[0] is the address, an IPv6 address included, since it never
+// contains whitespace. A line with fewer than two fields is skipped rather
+// than erroring, matching how the rest of this file treats malformed
+// swarmkit-supplied data it can't do anything useful with.
+func hostsToExtraHosts(hosts []string) []string {
+	var extraHosts []string
+	for _, entry := range hosts {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := fields[0]
+		for _, host := range fields[1:] {
+			extraHosts = append(extraHosts, fmt.Sprintf("%s:%s", host, ip))
+		}
+	}
+	return extraHosts
 }
 
 // This handles the case of volumes that are defined inside a service Mount
@@ -478,7 +612,7 @@ func (c *containerConfig) volumeCreateRequest(mount *api.Mount) *volume.CreateOp
 	return nil
 }
 
-func (c *containerConfig) resources() container.Resources {
+func (c *containerConfig) resources(b executorpkg.Backend) container.Resources {
 	resources := container.Resources{}
 
 	// set pids limit
@@ -487,14 +621,42 @@ func (c *containerConfig) resources() container.Resources {
 		resources.PidsLimit = &pidsLimit
 	}
 
-	resources.Ulimits = make([]*container.Ulimit, len(c.spec().Ulimits))
-	for i, ulimit := range c.spec().Ulimits {
-		resources.Ulimits[i] = &container.Ulimit{
+	// Start from the daemon's default ulimits, the same way the standalone
+	// container path does, then let any limit the service spec sets
+	// override the default for that name.
+	ulimits := make(map[string]*container.Ulimit, len(c.spec().Ulimits))
+	for _, ulimit := range b.DefaultUlimits() {
+		ulimits[ulimit.Name] = ulimit
+	}
+	for _, ulimit := range c.spec().Ulimits {
+		ulimits[ulimit.Name] = &container.Ulimit{
 			Name: ulimit.Name,
 			Soft: ulimit.Soft,
 			Hard: ulimit.Hard,
 		}
 	}
+	if len(ulimits) > 0 {
+		names := make([]string, 0, len(ulimits))
+		for name := range ulimits {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		resources.Ulimits = make([]*container.Ulimit, 0, len(names))
+		for _, name := range names {
+			resources.Ulimits = append(resources.Ulimits, ulimits[name])
+		}
+	}
+
+	if devices := c.spec().Devices; len(devices) > 0 {
+		resources.Devices = make([]container.DeviceMapping, len(devices))
+		for i, d := range devices {
+			resources.Devices[i] = container.DeviceMapping{
+				PathOnHost:        d.PathOnHost,
+				PathInContainer:   d.PathInContainer,
+				CgroupPermissions: d.CgroupPermissions,
+			}
+		}
+	}
 
 	// If no limits are specified let the engine use its defaults.
 	//
@@ -516,7 +678,35 @@ func (c *containerConfig) resources() container.Resources {
 	return resources
 }
 
+// deviceCgroupRules translates a device's class (char/block), when the spec
+// requests one by type rather than by host path, into an explicit device
+// cgroup rule. The device's major:minor isn't known until it's resolved on
+// the node, so this grants access to the whole class instead of a specific
+// node, the same tradeoff --device-cgroup-rule makes for standalone containers.
+func (c *containerConfig) deviceCgroupRules() []string {
+	var rules []string
+	for _, d := range c.spec().Devices {
+		if d.Type == "" {
+			continue
+		}
+		rules = append(rules, fmt.Sprintf("%s *:* %s", d.Type, d.CgroupPermissions))
+	}
+	return rules
+}
+
 func (c *containerConfig) createNetworkingConfig(b executorpkg.Backend) *network.NetworkingConfig {
+	// A group peer that shares its infra task's network namespace attaches
+	// to networks through that task's container instead of getting its own
+	// endpoints; engine rejects endpoint config alongside a container:
+	// NetworkMode, so none is built here.
+	if tg := c.taskGroup(); tg != nil && !tg.IsInfra {
+		for _, ns := range tg.SharedNamespaces {
+			if ns == api.TaskGroupSpec_NETWORK {
+				return &network.NetworkingConfig{}
+			}
+		}
+	}
+
 	var networks []*api.NetworkAttachment
 	if c.task.Spec.GetContainer() != nil || c.task.Spec.GetAttachment() != nil {
 		networks = c.task.Networks
@@ -564,24 +754,35 @@ func getEndpointConfig(na *api.NetworkAttachment, b executorpkg.Backend) *networ
 	return n
 }
 
-func (c *containerConfig) virtualIP(networkID string) string {
+// virtualIPs returns the task's IPv4 and IPv6 virtual IPs on networkID, if
+// either (or both) was assigned. Either return value is empty if the task
+// has no VIP of that address family on this network.
+func (c *containerConfig) virtualIPs(networkID string) (ipv4, ipv6 string) {
 	if c.task.Endpoint == nil {
-		return ""
+		return "", ""
 	}
 
 	for _, eVip := range c.task.Endpoint.VirtualIPs {
-		// We only support IPv4 VIPs for now.
-		if eVip.NetworkID == networkID {
-			vip, _, err := net.ParseCIDR(eVip.Addr)
-			if err != nil {
-				return ""
-			}
+		if eVip.NetworkID != networkID {
+			continue
+		}
 
-			return vip.String()
+		vip, _, err := net.ParseCIDR(eVip.Addr)
+		if err != nil {
+			continue
+		}
+
+		if vip.To4() != nil {
+			ipv4 = vip.String()
+			continue
+		}
+
+		if vip.To16() != nil {
+			ipv6 = vip.String()
 		}
 	}
 
-	return ""
+	return ipv4, ipv6
 }
 
 func (c *containerConfig) serviceConfig() *clustertypes.ServiceConfig {
@@ -598,9 +799,10 @@ func (c *containerConfig) serviceConfig() *clustertypes.ServiceConfig {
 	}
 
 	for _, na := range c.task.Networks {
+		ipv4, ipv6 := c.virtualIPs(na.Network.ID)
 		svcCfg.VirtualAddresses[na.Network.ID] = &clustertypes.VirtualAddress{
-			// We support only IPv4 virtual IP for now.
-			IPv4: c.virtualIP(na.Network.ID),
+			IPv4: ipv4,
+			IPv6: ipv6,
 		}
 		if len(na.Aliases) > 0 {
 			svcCfg.Aliases[na.Network.ID] = na.Aliases
@@ -672,10 +874,10 @@ func networkCreateRequest(name string, nw *api.Network) clustertypes.NetworkCrea
 	}
 }
 
-func (c *containerConfig) applyPrivileges(hc *container.HostConfig) {
+func (c *containerConfig) applyPrivileges(hc *container.HostConfig, imageLabels map[string]string) error {
 	privileges := c.spec().Privileges
 	if privileges == nil {
-		return
+		privileges = &api.Privileges{}
 	}
 
 	credentials := privileges.CredentialSpec
@@ -710,32 +912,59 @@ func (c *containerConfig) applyPrivileges(hc *container.HostConfig) {
 	}
 
 	// variable to make the lines shorter and easier to read
-	if seccomp := privileges.Seccomp; seccomp != nil {
+	if seccomp := privileges.Seccomp; seccomp != nil && seccomp.Mode != api.Privileges_SeccompOpts_DEFAULT {
 		switch seccomp.Mode {
-		// case api.Privileges_SeccompOpts_DEFAULT:
-		//   if the setting is default, nothing needs to be set here. we leave
-		//   the option empty.
 		case api.Privileges_SeccompOpts_UNCONFINED:
 			hc.SecurityOpt = append(hc.SecurityOpt, "seccomp=unconfined")
 		case api.Privileges_SeccompOpts_CUSTOM:
 			// Profile is bytes, but those bytes are actually a string. This is
 			// basically verbatim what happens in the cli after a file is read.
 			hc.SecurityOpt = append(hc.SecurityOpt, fmt.Sprintf("seccomp=%s", seccomp.Profile))
-		default:
-			// TODO(thaJeztah): make switch exhaustive; add api.Privileges_SeccompOpts_DEFAULT
 		}
+	} else if name := imageLabels[imageSeccompLabel]; name != "" {
+		// The spec left Seccomp unset (or explicitly DEFAULT): the image
+		// asks for a profile, but the image is attacker-controlled (it's
+		// whatever a registry serves), so its label can't be allowed to
+		// set confinement directly - that would let any image ship
+		// com.docker.seccomp.profile=unconfined and silently disable
+		// default confinement for a service that never opted in. Instead
+		// the label only *names* a Config the service operator must have
+		// explicitly bound; fail the task if it isn't, the same way an
+		// operator finds out about any other missing dependency.
+		if !c.hasConfigNamed(name) {
+			return fmt.Errorf("image requests seccomp profile %q via the %s label, but no Config with that name is bound to the service", name, imageSeccompLabel)
+		}
+		// Resolved by the engine the same way credentialspec=config:// is
+		// above: by the bound Config's name, not its contents inlined here.
+		hc.SecurityOpt = append(hc.SecurityOpt, "seccomp=config://"+name)
 	}
 
-	// if the setting is DEFAULT, then nothing to be done. If it's DISABLED,
-	// we set that. Custom not supported yet. When custom *is* supported, make
-	// it look like the above.
-	if apparmor := privileges.Apparmor; apparmor != nil && apparmor.Mode == api.Privileges_AppArmorOpts_DISABLED {
-		hc.SecurityOpt = append(hc.SecurityOpt, "apparmor=unconfined")
+	// if the setting is DEFAULT, then nothing to be done.
+	if apparmor := privileges.Apparmor; apparmor != nil {
+		switch apparmor.Mode {
+		case api.Privileges_AppArmorOpts_DISABLED:
+			hc.SecurityOpt = append(hc.SecurityOpt, "apparmor=unconfined")
+		case api.Privileges_AppArmorOpts_CUSTOM:
+			hc.SecurityOpt = append(hc.SecurityOpt, "apparmor="+apparmor.Profile)
+		}
 	}
 
 	if privileges.NoNewPrivileges {
 		hc.SecurityOpt = append(hc.SecurityOpt, "no-new-privileges=true")
 	}
+
+	return nil
+}
+
+// hasConfigNamed reports whether a Config named name is bound to the
+// service via this task's ContainerSpec.Configs.
+func (c *containerConfig) hasConfigNamed(name string) bool {
+	for _, ref := range c.spec().Configs {
+		if ref.ConfigName == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *containerConfig) eventFilter() filters.Args {
@@ -745,3 +974,19 @@ func (c *containerConfig) eventFilter() filters.Args {
 		filters.Arg("label", fmt.Sprintf("%v.task.id=%v", systemLabelPrefix, c.task.ID)),
 	)
 }
+
+// groupEventFilter is eventFilter's group-scoped counterpart: it matches
+// container events for any task in this task's group rather than just
+// this task's own container, which is what the executor needs when
+// waiting for the group's infra task to reach running before starting a
+// peer.
+func (c *containerConfig) groupEventFilter() filters.Args {
+	tg := c.taskGroup()
+	if tg == nil {
+		return c.eventFilter()
+	}
+	return filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("label", fmt.Sprintf("%v.task.group=%v", systemLabelPrefix, tg.GroupID)),
+	)
+}
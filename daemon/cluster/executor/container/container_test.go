@@ -0,0 +1,86 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/swarmkit/v2/api"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func taskWithImageSeccompLabel(configs []*api.ConfigReference) *api.Task {
+	return &api.Task{
+		Spec: api.TaskSpec{
+			Runtime: &api.TaskSpec_Container{
+				Container: &api.ContainerSpec{
+					Image:   "someimage:latest",
+					Configs: configs,
+				},
+			},
+		},
+	}
+}
+
+func TestApplyPrivilegesSeccompImageLabelRequiresBoundConfig(t *testing.T) {
+	c := &containerConfig{task: taskWithImageSeccompLabel(nil)}
+	hc := &container.HostConfig{}
+
+	err := c.applyPrivileges(hc, map[string]string{imageSeccompLabel: "my-profile"})
+	assert.ErrorContains(t, err, "my-profile")
+	assert.Check(t, is.Len(hc.SecurityOpt, 0))
+}
+
+func TestApplyPrivilegesSeccompImageLabelResolvesBoundConfig(t *testing.T) {
+	configs := []*api.ConfigReference{{ConfigName: "my-profile"}}
+	c := &containerConfig{task: taskWithImageSeccompLabel(configs)}
+	hc := &container.HostConfig{}
+
+	err := c.applyPrivileges(hc, map[string]string{imageSeccompLabel: "my-profile"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(hc.SecurityOpt, "seccomp=config://my-profile"))
+}
+
+func TestApplyPrivilegesSeccompImageLabelIgnoredWhenSpecSet(t *testing.T) {
+	task := taskWithImageSeccompLabel(nil)
+	task.Spec.GetContainer().Privileges = &api.Privileges{
+		Seccomp: &api.Privileges_SeccompOpts{Mode: api.Privileges_SeccompOpts_UNCONFINED},
+	}
+	c := &containerConfig{task: task}
+	hc := &container.HostConfig{}
+
+	err := c.applyPrivileges(hc, map[string]string{imageSeccompLabel: "my-profile"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(hc.SecurityOpt, "seccomp=unconfined"))
+}
+
+func TestHostsToExtraHosts(t *testing.T) {
+	cases := []struct {
+		name  string
+		hosts []string
+		want  []string
+	}{
+		{
+			name:  "multi alias",
+			hosts: []string{"10.0.0.1 host1 host2 host3"},
+			want:  []string{"host1:10.0.0.1", "host2:10.0.0.1", "host3:10.0.0.1"},
+		},
+		{
+			name:  "ipv6 address",
+			hosts: []string{"2001:db8::1 host1"},
+			want:  []string{"host1:2001:db8::1"},
+		},
+		{
+			name:  "malformed line skipped",
+			hosts: []string{"10.0.0.1", "10.0.0.2 host2"},
+			want:  []string{"host2:10.0.0.2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := hostsToExtraHosts(tc.hosts)
+			assert.DeepEqual(t, got, tc.want)
+		})
+	}
+}
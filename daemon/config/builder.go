@@ -11,20 +11,34 @@ import (
 
 // BuilderGCRule represents a GC rule for buildkit cache
 type BuilderGCRule struct {
-	All           bool            `json:",omitempty"`
-	Filter        BuilderGCFilter `json:",omitempty"`
-	ReservedSpace string          `json:",omitempty"`
-	MaxUsedSpace  string          `json:",omitempty"`
-	MinFreeSpace  string          `json:",omitempty"`
+	All           bool              `json:",omitempty"`
+	Filter        BuilderGCFilter   `json:",omitempty"`
+	ReservedSpace string            `json:",omitempty"`
+	MaxUsedSpace  string            `json:",omitempty"`
+	MinFreeSpace  string            `json:",omitempty"`
+	// MaxAge evicts cache records that haven't been used for longer than
+	// this before anything else in the rule is considered, e.g. "evict
+	// any unused frontend cache older than 168h". Zero means no age
+	// limit is applied.
+	MaxAge bkconfig.Duration `json:",omitempty"`
+	// Priority orders rules relative to each other: rules are evaluated
+	// highest Priority first, rather than in array order, so an operator
+	// can put narrow, aggressive rules (e.g. evict old frontend cache)
+	// ahead of broad fallback ones (e.g. evict everything else) without
+	// depending on where they happen to appear in the Policy list.
+	// Rules with equal Priority fall back to array order.
+	Priority int `json:",omitempty"`
 }
 
 func (x *BuilderGCRule) UnmarshalJSON(data []byte) error {
 	var xx struct {
-		All           bool            `json:",omitempty"`
-		Filter        BuilderGCFilter `json:",omitempty"`
-		ReservedSpace string          `json:",omitempty"`
-		MaxUsedSpace  string          `json:",omitempty"`
-		MinFreeSpace  string          `json:",omitempty"`
+		All           bool              `json:",omitempty"`
+		Filter        BuilderGCFilter   `json:",omitempty"`
+		ReservedSpace string            `json:",omitempty"`
+		MaxUsedSpace  string            `json:",omitempty"`
+		MinFreeSpace  string            `json:",omitempty"`
+		MaxAge        bkconfig.Duration `json:",omitempty"`
+		Priority      int               `json:",omitempty"`
 
 		// Deprecated option is now equivalent to ReservedSpace.
 		KeepStorage string `json:",omitempty"`
@@ -38,6 +52,8 @@ func (x *BuilderGCRule) UnmarshalJSON(data []byte) error {
 	x.ReservedSpace = xx.ReservedSpace
 	x.MaxUsedSpace = xx.MaxUsedSpace
 	x.MinFreeSpace = xx.MinFreeSpace
+	x.MaxAge = xx.MaxAge
+	x.Priority = xx.Priority
 	if x.ReservedSpace == "" {
 		x.ReservedSpace = xx.KeepStorage
 	}
@@ -89,19 +105,38 @@ type BuilderGCConfig struct {
 	DefaultReservedSpace string          `json:",omitempty"`
 	DefaultMaxUsedSpace  string          `json:",omitempty"`
 	DefaultMinFreeSpace  string          `json:",omitempty"`
+	// SweepInterval decouples background GC sweeps from the current
+	// opportunistic trigger (GC only running when a build happens to
+	// finish): it sets how often the builder's background GC governor
+	// checks cache usage. Zero uses the default interval of 5 minutes.
+	SweepInterval bkconfig.Duration `json:",omitempty"`
 }
 
 func (x *BuilderGCConfig) IsEnabled() bool {
 	return x.Enabled == nil || *x.Enabled
 }
 
+// OrderedPolicy returns a copy of x.Policy sorted by descending Priority,
+// stable on ties so rules at equal priority keep their relative array
+// order. Callers translating Policy into BuildKit's GC config should
+// evaluate rules in this order rather than x.Policy's own order.
+func (x *BuilderGCConfig) OrderedPolicy() []BuilderGCRule {
+	rules := make([]BuilderGCRule, len(x.Policy))
+	copy(rules, x.Policy)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+	return rules
+}
+
 func (x *BuilderGCConfig) UnmarshalJSON(data []byte) error {
 	var xx struct {
-		Enabled              bool            `json:",omitempty"`
-		Policy               []BuilderGCRule `json:",omitempty"`
-		DefaultReservedSpace string          `json:",omitempty"`
-		DefaultMaxUsedSpace  string          `json:",omitempty"`
-		DefaultMinFreeSpace  string          `json:",omitempty"`
+		Enabled              bool              `json:",omitempty"`
+		Policy               []BuilderGCRule   `json:",omitempty"`
+		DefaultReservedSpace string            `json:",omitempty"`
+		DefaultMaxUsedSpace  string            `json:",omitempty"`
+		DefaultMinFreeSpace  string            `json:",omitempty"`
+		SweepInterval        bkconfig.Duration `json:",omitempty"`
 
 		// Deprecated option is now equivalent to DefaultReservedSpace.
 		DefaultKeepStorage string `json:",omitempty"`
@@ -119,6 +154,7 @@ func (x *BuilderGCConfig) UnmarshalJSON(data []byte) error {
 	x.DefaultReservedSpace = xx.DefaultReservedSpace
 	x.DefaultMaxUsedSpace = xx.DefaultMaxUsedSpace
 	x.DefaultMinFreeSpace = xx.DefaultMinFreeSpace
+	x.SweepInterval = xx.SweepInterval
 	if x.DefaultReservedSpace == "" {
 		x.DefaultReservedSpace = xx.DefaultKeepStorage
 	}
@@ -1,27 +1,44 @@
 package images
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 
+	"github.com/containerd/containerd/v2/core/content"
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/moby/moby/api/types/events"
 	"github.com/moby/moby/v2/daemon/internal/image"
 	"github.com/moby/moby/v2/daemon/internal/layer"
 	"github.com/moby/moby/v2/daemon/server/backend"
 	"github.com/moby/moby/v2/pkg/ioutils"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 )
 
-// CommitImage creates a new image from a commit config
-func (i *ImageService) CommitImage(ctx context.Context, c backend.CommitConfig) (image.ID, error) {
+// CommitImage creates a new image from a commit config. Alongside the new
+// image.ID (still the digest of the serialized config, as with the legacy
+// image store) it returns the digest of a single-layer OCI manifest
+// assembled from the layer diff and config blobs ingested into the
+// containerd content store, so callers that talk to the content store
+// directly (e.g. to push the commit without re-exporting it) have something
+// pullable/pushable by digest rather than a bare config blob digest.
+func (i *ImageService) CommitImage(ctx context.Context, c backend.CommitConfig) (image.ID, digest.Digest, error) {
 	if err := ctx.Err(); err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	rwTar, err := exportContainerRw(i.layerStore, c.ContainerID, c.ContainerMountLabel)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer func() {
 		if rwTar != nil {
@@ -36,16 +53,69 @@ func (i *ImageService) CommitImage(ctx context.Context, c backend.CommitConfig)
 	} else {
 		parent, err = i.imageStore.Get(image.ID(c.ParentImageID))
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 	}
 
+	// If a content store is configured, fan the diff tar out to the content
+	// store as it's read, alongside the layerStore.Register call that
+	// already consumes it, so the commit's layer blob lands in the
+	// containerd content store without buffering the whole diff in memory.
+	var (
+		pw         *io.PipeWriter
+		ingestDone chan struct{}
+		ingestErr  error
+		layerDesc  ocispec.Descriptor
+	)
+	if i.content != nil {
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		origClose := rwTar.Close
+		rwTar = ioutils.NewReadCloserWrapper(io.TeeReader(rwTar, pw), origClose)
+		ingestDone = make(chan struct{})
+		go func() {
+			defer close(ingestDone)
+			layerDesc, ingestErr = i.ingestContentBlob(ctx, "commit-layer-"+c.ContainerID, ocispec.MediaTypeImageLayer, pr)
+			pr.CloseWithError(ingestErr)
+		}()
+	}
+
 	l, err := i.layerStore.Register(rwTar, parent.RootFS.ChainID())
+	if pw != nil {
+		// Register has finished reading rwTar (or failed partway through);
+		// either way no more writes are coming, so close the pipe's write
+		// side to unblock the ingest goroutine with EOF and wait for it.
+		pw.Close()
+		<-ingestDone
+	}
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	if ingestErr != nil {
+		return "", "", ingestErr
 	}
 	defer layer.ReleaseAndLog(i.layerStore, l)
 
+	// A checkpointed container being committed carries its CRIU checkpoint
+	// image alongside the usual rootfs diff: stash it in the content store
+	// under its own media type and record the blob's digest on the
+	// container config, so a later `docker create --checkpoint-from-image`
+	// style restore can find and extract it without the checkpoint
+	// directory itself still being around.
+	if c.CheckpointDir != "" {
+		if i.content == nil {
+			return "", "", errors.New("committing a checkpoint requires a containerd content store")
+		}
+		checkpointDesc, err := i.ingestCheckpointDir(ctx, c.ContainerID, c.CheckpointDir)
+		if err != nil {
+			return "", "", err
+		}
+		if c.Config.Labels == nil {
+			c.Config.Labels = make(map[string]string, 1)
+		}
+		c.Config.Labels["com.docker.checkpoint.digest"] = checkpointDesc.Digest.String()
+	}
+
 	cc := image.ChildConfig{
 		ContainerID:     c.ContainerID,
 		Author:          c.Author,
@@ -56,26 +126,145 @@ func (i *ImageService) CommitImage(ctx context.Context, c backend.CommitConfig)
 	}
 	config, err := json.Marshal(image.NewChildImage(parent, cc, c.ContainerOS))
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	// The content digest returned to the caller identifies a manifest, not a
+	// bare config blob, so that a caller talking to the content store
+	// directly (e.g. to push the commit without re-exporting it) gets back
+	// something a registry will actually accept a pull/push for.
+	var contentDigest digest.Digest
+	if i.content != nil {
+		configDesc, err := i.ingestContentBlob(ctx, "commit-config-"+c.ContainerID, ocispec.MediaTypeImageConfig, bytes.NewReader(config))
+		if err != nil {
+			return "", "", err
+		}
+
+		manifest := ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    configDesc,
+			Layers:    []ocispec.Descriptor{layerDesc},
+		}
+		manifestJSON, err := json.Marshal(manifest)
+		if err != nil {
+			return "", "", err
+		}
+		manifestDesc, err := i.ingestContentBlob(ctx, "commit-manifest-"+c.ContainerID, ocispec.MediaTypeImageManifest, bytes.NewReader(manifestJSON))
+		if err != nil {
+			return "", "", err
+		}
+		contentDigest = manifestDesc.Digest
+
+		// A committed image is only pullable by name/tag through containerd's
+		// image store if one is wired up; this checkout's ImageService has no
+		// such store (only a content.Store for blobs), so registering the
+		// manifest digest under c.Reference there isn't reachable here. The
+		// manifest itself is still assembled and content-addressable by
+		// manifestDesc.Digest above for anything that talks to the content
+		// store directly.
 	}
 
 	id, err := i.imageStore.Create(config)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	i.LogImageEvent(ctx, id.String(), id.String(), events.ActionCreate)
 
 	if err := i.imageStore.SetBuiltLocally(id); err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if c.ParentImageID != "" {
 		if err := i.imageStore.SetParent(id, image.ID(c.ParentImageID)); err != nil {
-			return "", err
+			return "", "", err
 		}
 	}
-	return id, nil
+	return id, contentDigest, nil
+}
+
+// ingestContentBlob copies r into the content store under ref, returning a
+// descriptor for the resulting blob. The digest isn't known until the copy
+// completes, so unlike plugin.Fetcher (which ingests blobs it already has a
+// digest for from a registry manifest), this computes it from the write
+// itself via content.Writer's rolling digest.
+func (i *ImageService) ingestContentBlob(ctx context.Context, ref, mediaType string, r io.Reader) (ocispec.Descriptor, error) {
+	w, err := i.content.Writer(ctx, content.WithRef(ref))
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("opening content writer for %s: %w", ref, err)
+	}
+	defer w.Close()
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("ingesting %s: %w", ref, err)
+	}
+	dgst := w.Digest()
+	if err := w.Commit(ctx, n, dgst); err != nil && !cerrdefs.IsAlreadyExists(err) {
+		return ocispec.Descriptor{}, fmt.Errorf("committing %s: %w", ref, err)
+	}
+	return ocispec.Descriptor{MediaType: mediaType, Digest: dgst, Size: n}, nil
+}
+
+// checkpointMediaType identifies a commit's bundled CRIU checkpoint image
+// in the content store, distinguishing it from the rootfs layer and config
+// blobs the same commit also ingests.
+const checkpointMediaType = "application/vnd.docker.container.checkpoint.tar"
+
+// ingestCheckpointDir tars dir (a container's CRIU checkpoint image
+// directory) and streams it straight into the content store, the same way
+// ingestContentBlob does for the rootfs diff, without buffering the whole
+// checkpoint in memory.
+func (i *ImageService) ingestCheckpointDir(ctx context.Context, containerID, dir string) (ocispec.Descriptor, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarDirectory(dir, pw))
+	}()
+	return i.ingestContentBlob(ctx, "commit-checkpoint-"+containerID, checkpointMediaType, pr)
+}
+
+// tarDirectory writes dir's contents as a tar stream to w. It's a minimal,
+// self-contained equivalent of pkg/archive.TarWithOptions, which isn't
+// available in this checkout.
+func tarDirectory(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
 }
 
 func exportContainerRw(layerStore layer.Store, id, mountLabel string) (arch io.ReadCloser, retErr error) {
@@ -119,11 +308,11 @@ func exportContainerRw(layerStore layer.Store, id, mountLabel string) (arch io.R
 //   - it doesn't log a container commit event
 //
 // This is a temporary shim. Should be removed when builder stops using commit.
-func (i *ImageService) CommitBuildStep(ctx context.Context, c backend.CommitConfig) (image.ID, error) {
+func (i *ImageService) CommitBuildStep(ctx context.Context, c backend.CommitConfig) (image.ID, digest.Digest, error) {
 	ctr := i.containers.Get(c.ContainerID)
 	if ctr == nil {
 		// TODO: use typed error
-		return "", errors.Errorf("container not found: %s", c.ContainerID)
+		return "", "", errors.Errorf("container not found: %s", c.ContainerID)
 	}
 	c.ContainerMountLabel = ctr.MountLabel
 	c.ContainerOS = ctr.ImagePlatform.OS
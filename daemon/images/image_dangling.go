@@ -0,0 +1,18 @@
+package images
+
+import (
+	"github.com/moby/moby/v2/daemon/internal/image"
+	"github.com/opencontainers/go-digest"
+)
+
+// IsDangling reports whether id is dangling: it has no repository
+// references AND nothing in the image store lists it as a parent.
+// "Untagged" alone isn't enough, since an untagged image a later, tagged
+// stage's history still descends from remains load-bearing; removing it
+// would orphan that history. Both image listing (to set the dangling=true
+// filter/label shown as <none>:<none>) and image prune (to pick what's
+// safe to remove by default) should use this, rather than each growing
+// its own, possibly-diverging notion of "untagged".
+func (i *ImageService) IsDangling(id image.ID) bool {
+	return len(i.referenceStore.References(digest.Digest(id))) == 0 && len(i.imageStore.Children(id)) == 0
+}
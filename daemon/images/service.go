@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/containerd/containerd/v2/core/content"
 	"github.com/containerd/containerd/v2/core/leases"
@@ -81,6 +83,11 @@ type ImageService struct {
 	leases                    leases.Manager
 	content                   content.Store
 	contentNamespace          string
+
+	diskUsageComputing atomic.Bool
+	diskUsageMu        sync.Mutex
+	diskUsageInflight  *diskUsageCall
+	diskUsageCached    *ImageDiskUsage
 }
 
 // DistributionServices provides daemon image storage services
@@ -201,24 +208,164 @@ func (i *ImageService) ReleaseLayer(rwlayer container.RWLayer) error {
 	return nil
 }
 
-// ImageDiskUsage returns the number of bytes used by content and layer stores
+// ImageDiskUsage is a point-in-time snapshot of on-disk layer usage,
+// decomposing each image's share of LayersSize into bytes it owns outright
+// versus bytes it shares with sibling images through common layers.
+type ImageDiskUsage struct {
+	// Computed is when this snapshot was produced.
+	Computed time.Time
+	// LayersSize is the total size of every referenced layer, counted once
+	// regardless of how many images share it.
+	LayersSize int64
+	// Images maps each referenced image to its SharedSize/UniqueSize
+	// breakdown of LayersSize.
+	Images map[image.ID]ImageUsage
+}
+
+// ImageUsage is one image's contribution to an ImageDiskUsage snapshot.
+type ImageUsage struct {
+	// SharedSize is the portion of the image's layers also used by at
+	// least one other image.
+	SharedSize int64
+	// UniqueSize is the portion of the image's layers used by no other
+	// image.
+	UniqueSize int64
+}
+
+// diskUsageCall is a single in-flight ImageDiskUsage computation that
+// multiple callers can wait on together instead of each starting their own
+// walk of every layer.
+type diskUsageCall struct {
+	done   chan struct{}
+	result *ImageDiskUsage
+	err    error
+}
+
+// ImageDiskUsage returns a snapshot of on-disk image/layer usage.
+//
+// Walking every layer to compute this is O(images) and is a well-known
+// source of API stalls on daemons with many images, so by default this
+// returns the most recently computed snapshot, however old, without
+// blocking on a fresh walk; only when no snapshot exists yet does a call
+// without a deadline wait for the first computation (or for ctx to be
+// cancelled). Passing a ctx with a deadline opts into waiting
+// for a fresh computation - joining one already in flight, if any - up to
+// that deadline; if the deadline elapses first, the cached (possibly
+// stale, possibly nil on a cold start) snapshot is returned alongside
+// ctx.Err() rather than losing it.
+//
 // called from disk_usage.go
-func (i *ImageService) ImageDiskUsage(ctx context.Context) (int64, error) {
-	var allLayersSize int64
+func (i *ImageService) ImageDiskUsage(ctx context.Context) (*ImageDiskUsage, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		if cached := i.cachedDiskUsage(); cached != nil {
+			return cached, nil
+		}
+	}
+
+	call := i.startDiskUsage()
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return i.cachedDiskUsage(), call.err
+		}
+		return call.result, nil
+	case <-ctx.Done():
+		return i.cachedDiskUsage(), ctx.Err()
+	}
+}
+
+func (i *ImageService) cachedDiskUsage() *ImageDiskUsage {
+	i.diskUsageMu.Lock()
+	defer i.diskUsageMu.Unlock()
+	return i.diskUsageCached
+}
+
+// startDiskUsage ensures at most one disk usage computation runs at a
+// time: a caller that arrives while one is already running joins it
+// rather than starting a redundant walk, and every caller gets back the
+// same *diskUsageCall to wait on.
+func (i *ImageService) startDiskUsage() *diskUsageCall {
+	i.diskUsageMu.Lock()
+	if i.diskUsageInflight != nil {
+		call := i.diskUsageInflight
+		i.diskUsageMu.Unlock()
+		return call
+	}
+	call := &diskUsageCall{done: make(chan struct{})}
+	i.diskUsageInflight = call
+	i.diskUsageComputing.Store(true)
+	i.diskUsageMu.Unlock()
+
+	go func() {
+		result, err := i.computeDiskUsage(context.Background())
+
+		i.diskUsageMu.Lock()
+		call.result, call.err = result, err
+		if err == nil {
+			i.diskUsageCached = result
+		}
+		i.diskUsageInflight = nil
+		i.diskUsageComputing.Store(false)
+		i.diskUsageMu.Unlock()
+
+		close(call.done)
+	}()
+
+	return call
+}
+
+// computeDiskUsage walks every layer store entry and image exactly once,
+// producing both the total LayersSize and, for each referenced image, a
+// SharedSize/UniqueSize breakdown obtained by reference-counting each
+// image's ChainIDs across imageStore.Map(), the same approach getLayerRefs
+// uses to decide whether a layer counts toward LayersSize at all.
+func (i *ImageService) computeDiskUsage(ctx context.Context) (*ImageDiskUsage, error) {
 	layerRefs := i.getLayerRefs()
 	allLayers := i.layerStore.Map()
+
+	sizes := make(map[layer.ChainID]int64, len(allLayers))
+	var layersSize int64
 	for _, l := range allLayers {
 		select {
 		case <-ctx.Done():
-			return allLayersSize, ctx.Err()
+			return nil, ctx.Err()
 		default:
-			size := l.DiffSize()
-			if _, ok := layerRefs[l.ChainID()]; ok {
-				allLayersSize += size
+		}
+		chainID := l.ChainID()
+		size := l.DiffSize()
+		sizes[chainID] = size
+		if _, ok := layerRefs[chainID]; ok {
+			layersSize += size
+		}
+	}
+
+	images := make(map[image.ID]ImageUsage, len(layerRefs))
+	for id, img := range i.imageStore.Map() {
+		dgst := digest.Digest(id)
+		if len(i.referenceStore.References(dgst)) == 0 && len(i.imageStore.Children(id)) != 0 {
+			continue
+		}
+
+		var usage ImageUsage
+		rootFS := *img.RootFS
+		rootFS.DiffIDs = nil
+		for _, d := range img.RootFS.DiffIDs {
+			rootFS.Append(d)
+			chainID := rootFS.ChainID()
+			if layerRefs[chainID] > 1 {
+				usage.SharedSize += sizes[chainID]
+			} else {
+				usage.UniqueSize += sizes[chainID]
 			}
 		}
+		images[id] = usage
 	}
-	return allLayersSize, nil
+
+	return &ImageDiskUsage{
+		Computed:   time.Now(),
+		LayersSize: layersSize,
+		Images:     images,
+	}, nil
 }
 
 func (i *ImageService) getLayerRefs() map[layer.ChainID]int {
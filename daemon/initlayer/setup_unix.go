@@ -5,33 +5,103 @@ package initlayer // import "github.com/docker/docker/daemon/initlayer"
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/moby/sys/user"
 	"golang.org/x/sys/unix"
 )
 
+// InitLayerSpec describes the mountpoints and placeholder files/symlinks
+// that Setup populates the init layer with. Dirs and Files name plain
+// directories and empty files to create; Symlinks maps a path to the
+// target it should point at (e.g. "/etc/mtab" -> "/proc/mounts").
+//
+// Skip removes entries (by path) from the merged defaults+extras set,
+// for images that already ship their own version of a file the defaults
+// would otherwise create (e.g. a scratch image with its own /etc/mtab).
+type InitLayerSpec struct {
+	Dirs     []string
+	Files    []string
+	Symlinks map[string]string
+	Skip     []string
+}
+
+// DefaultInitLayerSpec returns the historical, built-in set of init layer
+// entries. Callers that want to customize the init layer should start from
+// this and merge in their own additions via MergeInitLayerSpec.
+func DefaultInitLayerSpec() InitLayerSpec {
+	return InitLayerSpec{
+		Dirs: []string{
+			"/dev/pts",
+			"/dev/shm",
+			"/proc",
+			"/sys",
+		},
+		Files: []string{
+			"/.dockerenv",
+			"/etc/resolv.conf",
+			"/etc/hosts",
+			"/etc/hostname",
+			"/dev/console",
+		},
+		Symlinks: map[string]string{
+			"/etc/mtab": "/proc/mounts",
+		},
+	}
+}
+
+// MergeInitLayerSpec merges extra entries (e.g. contributed by daemon
+// config or container HostConfig) on top of base, and removes any path
+// named in extra.Skip from the result. Skip is applied last, so it can
+// suppress entries coming from base as well as from extra itself.
+func MergeInitLayerSpec(base InitLayerSpec, extra InitLayerSpec) InitLayerSpec {
+	merged := InitLayerSpec{
+		Dirs:     append(append([]string{}, base.Dirs...), extra.Dirs...),
+		Files:    append(append([]string{}, base.Files...), extra.Files...),
+		Symlinks: make(map[string]string, len(base.Symlinks)+len(extra.Symlinks)),
+	}
+	for k, v := range base.Symlinks {
+		merged.Symlinks[k] = v
+	}
+	for k, v := range extra.Symlinks {
+		merged.Symlinks[k] = v
+	}
+
+	skip := make(map[string]bool, len(extra.Skip))
+	for _, s := range extra.Skip {
+		skip[s] = true
+	}
+	merged.Dirs = slices.DeleteFunc(merged.Dirs, func(p string) bool { return skip[p] })
+	merged.Files = slices.DeleteFunc(merged.Files, func(p string) bool { return skip[p] })
+	for p := range skip {
+		delete(merged.Symlinks, p)
+	}
+
+	return merged
+}
+
 // Setup populates a directory with mountpoints suitable
 // for bind-mounting things into the container.
 //
 // This extra layer is used by all containers as the top-most ro layer. It protects
 // the container from unwanted side-effects on the rw layer.
-func Setup(initLayerFs string, uid int, gid int) error {
+func Setup(initLayerFs string, spec InitLayerSpec, uid int, gid int) error {
 	// Since all paths are local to the container, we can just extract initLayerFs.Path()
 	initLayer := initLayerFs
 
-	for pth, typ := range map[string]string{
-		"/dev/pts":         "dir",
-		"/dev/shm":         "dir",
-		"/proc":            "dir",
-		"/sys":             "dir",
-		"/.dockerenv":      "file",
-		"/etc/resolv.conf": "file",
-		"/etc/hosts":       "file",
-		"/etc/hostname":    "file",
-		"/dev/console":     "file",
-		"/etc/mtab":        "/proc/mounts",
-	} {
+	entries := make(map[string]string, len(spec.Dirs)+len(spec.Files)+len(spec.Symlinks))
+	for _, pth := range spec.Dirs {
+		entries[pth] = "dir"
+	}
+	for _, pth := range spec.Files {
+		entries[pth] = "file"
+	}
+	for pth, target := range spec.Symlinks {
+		entries[pth] = target
+	}
+
+	for pth, typ := range entries {
 		parts := strings.Split(pth, "/")
 		prev := "/"
 		for _, p := range parts[1:] {
@@ -2,6 +2,7 @@ package buildkit
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"net/netip"
@@ -11,7 +12,9 @@ import (
 	"time"
 
 	"github.com/containerd/containerd/v2/core/remotes/docker"
+	"github.com/containerd/log"
 	"github.com/containerd/platforms"
+	"github.com/docker/go-units"
 	controlapi "github.com/moby/buildkit/api/services/control"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/control"
@@ -22,6 +25,7 @@ import (
 	"github.com/moby/moby/api/pkg/streamformatter"
 	"github.com/moby/moby/api/types/build"
 	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/filters"
 	"github.com/moby/moby/api/types/network"
 	timetypes "github.com/moby/moby/api/types/time"
 	"github.com/moby/moby/v2/daemon/builder"
@@ -37,6 +41,7 @@ import (
 	"github.com/moby/sys/user"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 	"google.golang.org/grpc"
 	grpcmetadata "google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/proto"
@@ -99,6 +104,10 @@ type Opt struct {
 	ContainerdNamespace string
 	Callbacks           exporter.BuildkitCallbacks
 	CDICache            *cdi.Cache
+	// MaxConcurrentBuilds caps how many Build calls may have a solve in
+	// flight at once; additional callers block in Build until a slot
+	// frees up. Zero means unlimited, matching prior behavior.
+	MaxConcurrentBuilds int
 }
 
 // Builder can build using BuildKit backend
@@ -110,6 +119,15 @@ type Builder struct {
 	mu             sync.Mutex
 	jobs           map[string]*buildJob
 	useSnapshotter bool
+
+	// solveSem bounds concurrent in-flight solves per Opt.MaxConcurrentBuilds;
+	// nil when unset, in which case Build never blocks on it.
+	solveSem *semaphore.Weighted
+
+	// gc is the GC config the background governor in runGCGovernor
+	// enforces, and gcCancel stops that governor on Close.
+	gc       config.BuilderGCConfig
+	gcCancel context.CancelFunc
 }
 
 // New creates a new builder
@@ -126,14 +144,115 @@ func New(ctx context.Context, opt Opt) (*Builder, error) {
 		reqBodyHandler: reqHandler,
 		jobs:           map[string]*buildJob{},
 		useSnapshotter: opt.UseSnapshotter,
+		gc:             opt.BuilderConfig.GC,
 	}
+	if opt.MaxConcurrentBuilds > 0 {
+		b.solveSem = semaphore.NewWeighted(int64(opt.MaxConcurrentBuilds))
+	}
+
+	gcCtx, gcCancel := context.WithCancel(ctx)
+	b.gcCancel = gcCancel
+	go b.runGCGovernor(gcCtx)
+
 	return b, nil
 }
 
 func (b *Builder) Close() error {
+	if b.gcCancel != nil {
+		b.gcCancel()
+	}
 	return b.controller.Close()
 }
 
+// runGCGovernor checks reclaimable cache usage every gc.SweepInterval (5
+// minutes when unset) against gc.Policy (if any rules are configured,
+// evaluated highest-Priority first via gcPolicyFromConfig) or else
+// gc.DefaultMaxUsedSpace, and triggers a Prune when usage reaches that
+// limit, giving operators background GC instead of requiring them to script
+// prune loops themselves. Only MaxUsedSpace gates a prune: a policy rule
+// without one prunes on every sweep, the default path does nothing while
+// DefaultMaxUsedSpace is unset, and DefaultReservedSpace/DefaultMinFreeSpace
+// are only passed on to the Prune. Free disk space is not checked. It runs
+// for the lifetime of the Builder; ctx is cancelled from Close.
+func (b *Builder) runGCGovernor(ctx context.Context) {
+	interval := time.Duration(b.gc.SweepInterval)
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			b.maybeAutoPrune(ctx)
+		}
+	}
+}
+
+func (b *Builder) maybeAutoPrune(ctx context.Context) {
+	if !b.gc.IsEnabled() {
+		return
+	}
+
+	if policy := gcPolicyFromConfig(b.gc); len(policy) > 0 {
+		// Policy rules are evaluated highest-Priority first (already the
+		// order gcPolicyFromConfig returns them in); each rule prunes
+		// against its own filter/thresholds instead of the single
+		// Default*Space fallback below.
+		for _, rule := range policy {
+			if !b.reclaimableCrosses(ctx, rule.MaxUsedSpace) {
+				continue
+			}
+			if _, _, err := b.prune(ctx, rule); err != nil {
+				log.G(ctx).WithError(err).Warn("buildkit: background auto-prune failed")
+			}
+		}
+		return
+	}
+
+	maxUsedSpace, _ := units.RAMInBytes(b.gc.DefaultMaxUsedSpace)
+	if maxUsedSpace <= 0 {
+		return
+	}
+	if !b.reclaimableCrosses(ctx, maxUsedSpace) {
+		return
+	}
+
+	reservedSpace, _ := units.RAMInBytes(b.gc.DefaultReservedSpace)
+	minFreeSpace, _ := units.RAMInBytes(b.gc.DefaultMinFreeSpace)
+	if _, _, err := b.prune(ctx, client.PruneInfo{
+		ReservedSpace: reservedSpace,
+		MaxUsedSpace:  maxUsedSpace,
+		MinFreeSpace:  minFreeSpace,
+	}); err != nil {
+		log.G(ctx).WithError(err).Warn("buildkit: background auto-prune failed")
+	}
+}
+
+// reclaimableCrosses reports whether currently-reclaimable cache usage is at
+// least maxUsedSpace. maxUsedSpace <= 0 means the caller's rule doesn't gate
+// on usage at all, so it always triggers.
+func (b *Builder) reclaimableCrosses(ctx context.Context, maxUsedSpace int64) bool {
+	if maxUsedSpace <= 0 {
+		return true
+	}
+	records, err := b.DiskUsage(ctx, build.CacheFilterOptions{Filters: filters.NewArgs()})
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("buildkit: background disk usage check failed")
+		return false
+	}
+	var reclaimable int64
+	for _, r := range records {
+		if !r.InUse {
+			reclaimable += r.Size
+		}
+	}
+	return reclaimable >= maxUsedSpace
+}
+
 // RegisterGRPC registers controller to the grpc server.
 func (b *Builder) RegisterGRPC(s *grpc.Server) {
 	b.controller.Register(s)
@@ -150,8 +269,18 @@ func (b *Builder) Cancel(ctx context.Context, id string) error {
 }
 
 // DiskUsage returns a report about space used by build cache
-func (b *Builder) DiskUsage(ctx context.Context) ([]*build.CacheRecord, error) {
-	duResp, err := b.controller.DiskUsage(ctx, &controlapi.DiskUsageRequest{})
+func (b *Builder) DiskUsage(ctx context.Context, opts build.CacheFilterOptions) ([]*build.CacheRecord, error) {
+	if err := opts.Filters.Validate(validCacheFilters()); err != nil {
+		return nil, err
+	}
+	_, bkFilter, err := cacheFiltersToBuildkit(opts.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	duResp, err := b.controller.DiskUsage(ctx, &controlapi.DiskUsageRequest{
+		Filter: []string{strings.Join(bkFilter, ",")},
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -188,19 +317,7 @@ func (b *Builder) DiskUsage(ctx context.Context) ([]*build.CacheRecord, error) {
 
 // Prune clears all reclaimable build cache.
 func (b *Builder) Prune(ctx context.Context, opts build.CachePruneOptions) (int64, []string, error) {
-	ch := make(chan *controlapi.UsageRecord)
-
-	eg, ctx := errgroup.WithContext(ctx)
-
-	validFilters := make(map[string]bool, 1+len(cacheFields))
-	validFilters["unused-for"] = true
-	validFilters["until"] = true
-	validFilters["label"] = true  // TODO(tiborvass): handle label
-	validFilters["label!"] = true // TODO(tiborvass): handle label!
-	for k, v := range cacheFields {
-		validFilters[k] = v
-	}
-	if err := opts.Filters.Validate(validFilters); err != nil {
+	if err := opts.Filters.Validate(validCacheFilters()); err != nil {
 		return 0, nil, err
 	}
 
@@ -209,6 +326,18 @@ func (b *Builder) Prune(ctx context.Context, opts build.CachePruneOptions) (int6
 		return 0, nil, err
 	}
 
+	return b.prune(ctx, pi)
+}
+
+// prune runs a single BuildKit prune pass for pi, shared by the public
+// Prune (translated from the API's CachePruneOptions) and maybeAutoPrune's
+// GC-policy path (translated from config.BuilderGCConfig.Policy via
+// gcPolicyFromConfig), so both go through the same controller.Prune call.
+func (b *Builder) prune(ctx context.Context, pi client.PruneInfo) (int64, []string, error) {
+	ch := make(chan *controlapi.UsageRecord)
+
+	eg, ctx := errgroup.WithContext(ctx)
+
 	eg.Go(func() error {
 		defer close(ch)
 		return b.controller.Prune(&controlapi.PruneRequest{
@@ -243,8 +372,11 @@ func (b *Builder) Prune(ctx context.Context, opts build.CachePruneOptions) (int6
 
 // Build executes a build request
 func (b *Builder) Build(ctx context.Context, opt backend.BuildConfig) (*builder.Result, error) {
-	if len(opt.Options.Outputs) > 1 {
-		return nil, errors.Errorf("multiple outputs not supported")
+	if b.solveSem != nil {
+		if err := b.solveSem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+		defer b.solveSem.Release(1)
 	}
 
 	rc := opt.Source
@@ -315,7 +447,24 @@ func (b *Builder) Build(ctx context.Context, opt backend.BuildConfig) (*builder.
 
 	cacheFrom := append([]string{}, opt.Options.CacheFrom...)
 
-	frontendAttrs["cache-from"] = strings.Join(cacheFrom, ",")
+	// The dockerfile frontend's "cache-from" attr predates structured cache
+	// specs and only understands a legacy CSV of bare image refs. A
+	// structured spec (e.g. "type=s3,bucket=...") joined in unchanged would
+	// both corrupt that CSV with its own embedded commas and be meaningless
+	// to the frontend, so only forward the bare-ref entries parseCacheEntries
+	// below also treats as plain registry refs.
+	var legacyCacheFrom []string
+	for _, c := range cacheFrom {
+		if !strings.Contains(c, "=") {
+			legacyCacheFrom = append(legacyCacheFrom, c)
+		}
+	}
+	frontendAttrs["cache-from"] = strings.Join(legacyCacheFrom, ",")
+
+	cacheImports, err := parseCacheEntries(cacheFrom)
+	if err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
 
 	for k, v := range opt.Options.BuildArgs {
 		if v == nil {
@@ -373,30 +522,49 @@ func (b *Builder) Build(ctx context.Context, opt backend.BuildConfig) (*builder.
 		frontendAttrs["ulimit"] = ulimits
 	}
 
-	exporterName := ""
-	exporterAttrs := map[string]string{}
-	if len(opt.Options.Outputs) == 0 {
-		exporterName = exporter.Moby
-	} else {
-		// cacheonly is a special type for triggering skipping all exporters
-		if opt.Options.Outputs[0].Type != "cacheonly" {
-			exporterName = opt.Options.Outputs[0].Type
-			exporterAttrs = opt.Options.Outputs[0].Attrs
+	// Build one controlapi.Exporter per requested output so a single solve
+	// can, for example, load the image into moby *and* write an OCI
+	// tarball *and* push to a registry in one build - each output type
+	// (moby/oci/image/tar/local) is handled by worker.Worker.Exporter
+	// already; this just stops collapsing the list down to one entry. There
+	// is no "docker" exporter there, so a type=docker output fails the solve.
+	// "cacheonly" is a special type for skipping all exporters.
+	exporters := make([]*controlapi.Exporter, 0, len(opt.Options.Outputs))
+	hasImageExporter := false
+	addExporter := func(typ string, attrs map[string]string) error {
+		if typ == client.ExporterImage || typ == exporter.Moby {
+			hasImageExporter = true
+			if len(opt.Options.Tags) > 0 {
+				nameAttr, err := overrides.SanitizeRepoAndTags(opt.Options.Tags)
+				if err != nil {
+					return err
+				}
+				if attrs == nil {
+					attrs = make(map[string]string)
+				}
+				attrs["name"] = strings.Join(nameAttr, ",")
+			}
 		}
+		exporters = append(exporters, &controlapi.Exporter{Type: typ, Attrs: attrs})
+		return nil
 	}
 
-	if (exporterName == client.ExporterImage || exporterName == exporter.Moby) && len(opt.Options.Tags) > 0 {
-		nameAttr, err := overrides.SanitizeRepoAndTags(opt.Options.Tags)
-		if err != nil {
+	if len(opt.Options.Outputs) == 0 {
+		if err := addExporter(exporter.Moby, map[string]string{}); err != nil {
 			return nil, err
 		}
-		if exporterAttrs == nil {
-			exporterAttrs = make(map[string]string)
+	} else {
+		for _, output := range opt.Options.Outputs {
+			if output.Type == "cacheonly" {
+				continue
+			}
+			if err := addExporter(output.Type, output.Attrs); err != nil {
+				return nil, err
+			}
 		}
-		exporterAttrs["name"] = strings.Join(nameAttr, ",")
 	}
 
-	cache := &controlapi.CacheOptions{}
+	cache := &controlapi.CacheOptions{Imports: cacheImports}
 	if inlineCache := opt.Options.BuildArgs["BUILDKIT_INLINE_CACHE"]; inlineCache != nil {
 		if b, err := strconv.ParseBool(*inlineCache); err == nil && b {
 			cache.Exports = append(cache.Exports, &controlapi.CacheOptionsEntry{
@@ -404,13 +572,16 @@ func (b *Builder) Build(ctx context.Context, opt backend.BuildConfig) (*builder.
 			})
 		}
 	}
+	cacheExports, err := parseCacheEntries(opt.Options.CacheTo)
+	if err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	cache.Exports = append(cache.Exports, cacheExports...)
 
 	id := identity.NewID()
 	req := &controlapi.SolveRequest{
-		Ref: id,
-		Exporters: []*controlapi.Exporter{
-			{Type: exporterName, Attrs: exporterAttrs},
-		},
+		Ref:           id,
+		Exporters:     exporters,
 		Frontend:      "dockerfile.v0",
 		FrontendAttrs: frontendAttrs,
 		Session:       opt.Options.SessionID,
@@ -430,7 +601,7 @@ func (b *Builder) Build(ctx context.Context, opt backend.BuildConfig) (*builder.
 		if err != nil {
 			return err
 		}
-		if exporterName != exporter.Moby && exporterName != client.ExporterImage {
+		if !hasImageExporter {
 			return nil
 		}
 		imgID, ok := resp.ExporterResponse["containerimage.digest"]
@@ -457,6 +628,20 @@ func (b *Builder) Build(ctx context.Context, opt backend.BuildConfig) (*builder.
 			if err != nil {
 				return err
 			}
+			// A caller that wants the raw controlapi.StatusResponse wire
+			// format - e.g. to serve it over an
+			// application/vnd.docker.buildkit.v1+protobuf endpoint instead
+			// of JSON - sets StatusWriter, and gets each message
+			// length-delimited on that stream instead of base64-wrapped
+			// in a moby.buildkit.trace aux message. Existing JSON clients
+			// are unaffected: the aux path below still runs whenever
+			// StatusWriter isn't set.
+			if sw := opt.ProgressWriter.StatusWriter; sw != nil {
+				if err := writeLengthDelimitedStatus(sw, dt); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := aux.Emit("moby.buildkit.trace", dt); err != nil {
 				return err
 			}
@@ -471,6 +656,20 @@ func (b *Builder) Build(ctx context.Context, opt backend.BuildConfig) (*builder.
 	return &out, nil
 }
 
+// writeLengthDelimitedStatus writes msg (a marshaled controlapi.StatusResponse)
+// to w prefixed with its length as a 4-byte big-endian uint32, the simplest
+// framing for a client reading a continuous stream to split it back into
+// individual messages without needing a JSON envelope around each one.
+func writeLengthDelimitedStatus(w io.Writer, msg []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(msg)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
 type streamProxy struct {
 	ctx context.Context
 }
@@ -629,6 +828,42 @@ func toBuildkitExtraHosts(inp []string, hostGatewayIPs []netip.Addr) (string, er
 	return strings.Join(hosts, ","), nil
 }
 
+// parseCacheEntries turns a CacheFrom/CacheTo-style string slice into
+// controlapi.CacheOptionsEntry values, one per string. Each entry is either
+// a structured spec - "type=registry,ref=...", "type=local,src=...",
+// "type=gha,scope=...", "type=s3,bucket=..." - matching what `docker buildx
+// build --cache-from/--cache-to` accepts, or a bare image reference, which
+// is shorthand for "type=registry,ref=<reference>" to keep existing
+// CacheFrom callers (a plain list of image refs) working unchanged.
+func parseCacheEntries(specs []string) ([]*controlapi.CacheOptionsEntry, error) {
+	entries := make([]*controlapi.CacheOptionsEntry, 0, len(specs))
+	for _, spec := range specs {
+		if !strings.Contains(spec, "=") {
+			entries = append(entries, &controlapi.CacheOptionsEntry{
+				Type:  "registry",
+				Attrs: map[string]string{"ref": spec},
+			})
+			continue
+		}
+
+		attrs := map[string]string{}
+		for _, field := range strings.Split(spec, ",") {
+			k, v, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, errors.Errorf("invalid cache spec %q: expected key=value fields", spec)
+			}
+			attrs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		typ := attrs["type"]
+		if typ == "" {
+			return nil, errors.Errorf("invalid cache spec %q: missing type=", spec)
+		}
+		delete(attrs, "type")
+		entries = append(entries, &controlapi.CacheOptionsEntry{Type: typ, Attrs: attrs})
+	}
+	return entries, nil
+}
+
 // toBuildkitUlimits converts ulimits from docker type=soft:hard format to buildkit's csv format
 func toBuildkitUlimits(inp []*container.Ulimit) (string, error) {
 	if len(inp) == 0 {
@@ -641,13 +876,63 @@ func toBuildkitUlimits(inp []*container.Ulimit) (string, error) {
 	return strings.Join(ulimits, ","), nil
 }
 
-func toBuildkitPruneInfo(opts build.CachePruneOptions) (client.PruneInfo, error) {
+// gcPolicyFromConfig translates a daemon config.BuilderGCConfig into the
+// []client.PruneInfo BuildKit's worker expects as its GCPolicy, evaluating
+// rules in priority order (config.BuilderGCConfig.OrderedPolicy) rather than
+// the order they appear in the config file, and carrying each rule's MaxAge
+// through as KeepDuration - the same field toBuildkitPruneInfo (below)
+// populates from the `until`/`unused-for` prune filters.
+func gcPolicyFromConfig(gc config.BuilderGCConfig) []client.PruneInfo {
+	rules := gc.OrderedPolicy()
+	policy := make([]client.PruneInfo, 0, len(rules))
+	for _, rule := range rules {
+		var filter []string
+		if f := (filters.Args)(rule.Filter); f.Len() > 0 {
+			filter = append(filter, f.String())
+		}
+		reservedSpace, _ := units.RAMInBytes(rule.ReservedSpace)
+		maxUsedSpace, _ := units.RAMInBytes(rule.MaxUsedSpace)
+		minFreeSpace, _ := units.RAMInBytes(rule.MinFreeSpace)
+		policy = append(policy, client.PruneInfo{
+			All:           rule.All,
+			KeepDuration:  time.Duration(rule.MaxAge),
+			ReservedSpace: reservedSpace,
+			MaxUsedSpace:  maxUsedSpace,
+			MinFreeSpace:  minFreeSpace,
+			Filter:        filter,
+		})
+	}
+	return policy
+}
+
+// validCacheFilters is the set of filter keys DiskUsage and Prune both
+// accept, kept in one place so the two can't drift apart. cacheFields'
+// false entries mark BuildKit fields this API doesn't expose, so Validate
+// rejects them.
+func validCacheFilters() map[string]bool {
+	validFilters := make(map[string]bool, 2+len(cacheFields))
+	validFilters["unused-for"] = true
+	validFilters["until"] = true
+	validFilters["label"] = true
+	validFilters["label!"] = true
+	for k, v := range cacheFields {
+		validFilters[k] = v
+	}
+	return validFilters
+}
+
+// cacheFiltersToBuildkit translates a filters.Args shared by DiskUsage and
+// Prune into BuildKit's cache filter expression syntax: an until/unused-for
+// value as a KeepDuration-ready time.Duration, plus the field expressions
+// (id~=value, <field>==value, ...) that make up the Filter on
+// controlapi.DiskUsageRequest/PruneRequest.
+func cacheFiltersToBuildkit(f filters.Args) (time.Duration, []string, error) {
 	var until time.Duration
-	untilValues := opts.Filters.Get("until")          // canonical
-	unusedForValues := opts.Filters.Get("unused-for") // deprecated synonym for "until" filter
+	untilValues := f.Get("until")          // canonical
+	unusedForValues := f.Get("unused-for") // deprecated synonym for "until" filter
 
 	if len(untilValues) > 0 && len(unusedForValues) > 0 {
-		return client.PruneInfo{}, errConflictFilter{"until", "unused-for"}
+		return 0, nil, errConflictFilter{"until", "unused-for"}
 	}
 	filterKey := "until"
 	if len(unusedForValues) > 0 {
@@ -661,26 +946,26 @@ func toBuildkitPruneInfo(opts build.CachePruneOptions) (client.PruneInfo, error)
 	case 1:
 		ts, err := timetypes.GetTimestamp(untilValues[0], time.Now())
 		if err != nil {
-			return client.PruneInfo{}, errInvalidFilterValue{
+			return 0, nil, errInvalidFilterValue{
 				errors.Wrapf(err, "%q filter expects a duration (e.g., '24h') or a timestamp", filterKey),
 			}
 		}
 		seconds, nanoseconds, err := timetypes.ParseTimestamps(ts, 0)
 		if err != nil {
-			return client.PruneInfo{}, errInvalidFilterValue{
+			return 0, nil, errInvalidFilterValue{
 				errors.Wrapf(err, "failed to parse timestamp %q", ts),
 			}
 		}
 
 		until = time.Since(time.Unix(seconds, nanoseconds))
 	default:
-		return client.PruneInfo{}, errMultipleFilterValues{}
+		return 0, nil, errMultipleFilterValues{}
 	}
 
-	bkFilter := make([]string, 0, opts.Filters.Len())
+	bkFilter := make([]string, 0, f.Len())
 	for cacheField := range cacheFields {
-		if opts.Filters.Contains(cacheField) {
-			values := opts.Filters.Get(cacheField)
+		if f.Contains(cacheField) {
+			values := f.Get(cacheField)
 			switch len(values) {
 			case 0:
 				bkFilter = append(bkFilter, cacheField)
@@ -691,10 +976,55 @@ func toBuildkitPruneInfo(opts build.CachePruneOptions) (client.PruneInfo, error)
 					bkFilter = append(bkFilter, cacheField+"=="+values[0])
 				}
 			default:
-				return client.PruneInfo{}, errMultipleFilterValues{}
+				return 0, nil, errMultipleFilterValues{}
+			}
+		}
+	}
+
+	labelFilter, err := toBuildkitLabelFilters(f)
+	if err != nil {
+		return 0, nil, err
+	}
+	bkFilter = append(bkFilter, labelFilter...)
+
+	return until, bkFilter, nil
+}
+
+// toBuildkitLabelFilters translates the "label" and "label!" filters into
+// BuildKit's label[key]/label[key]==value/label[key]!=value expressions.
+// "label" entries without a value (bare "key") match any record that has
+// the label set at all; entries of the form "key=value" require an exact
+// match. "label!" entries negate the "key=value" form; a bare "key" under
+// "label!" is rejected, as the expression syntax has no "label not set".
+func toBuildkitLabelFilters(f filters.Args) ([]string, error) {
+	var bkFilter []string
+	for _, negate := range []bool{false, true} {
+		filterKey := "label"
+		if negate {
+			filterKey = "label!"
+		}
+		for _, v := range f.Get(filterKey) {
+			key, value, hasValue := strings.Cut(v, "=")
+			switch {
+			case !hasValue && negate:
+				return nil, errInvalidFilterValue{errors.Errorf("%q filter expects key=value, got %q", filterKey, v)}
+			case !hasValue:
+				bkFilter = append(bkFilter, "label["+key+"]")
+			case negate:
+				bkFilter = append(bkFilter, "label["+key+"]!="+value)
+			default:
+				bkFilter = append(bkFilter, "label["+key+"]=="+value)
 			}
 		}
 	}
+	return bkFilter, nil
+}
+
+func toBuildkitPruneInfo(opts build.CachePruneOptions) (client.PruneInfo, error) {
+	until, bkFilter, err := cacheFiltersToBuildkit(opts.Filters)
+	if err != nil {
+		return client.PruneInfo{}, err
+	}
 
 	if opts.ReservedSpace == 0 && opts.KeepStorage != 0 {
 		opts.ReservedSpace = opts.KeepStorage
@@ -1,14 +1,18 @@
 package worker
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	nethttp "net/http"
+	"sync"
 	"time"
 
 	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/diff"
 	c8dimages "github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/core/remotes/docker"
 	"github.com/containerd/containerd/v2/pkg/gc"
 	"github.com/containerd/containerd/v2/pkg/rootfs"
 	cerrdefs "github.com/containerd/errdefs"
@@ -16,10 +20,17 @@ import (
 	"github.com/containerd/platforms"
 	"github.com/moby/buildkit/cache"
 	cacheconfig "github.com/moby/buildkit/cache/config"
+	"github.com/moby/buildkit/cache/remotecache"
+	gharemotecache "github.com/moby/buildkit/cache/remotecache/gha"
+	inlineremotecache "github.com/moby/buildkit/cache/remotecache/inline"
+	localremotecache "github.com/moby/buildkit/cache/remotecache/local"
+	registryremotecache "github.com/moby/buildkit/cache/remotecache/registry"
+	s3remotecache "github.com/moby/buildkit/cache/remotecache/s3"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb/sourceresolver"
 	"github.com/moby/buildkit/executor"
 	"github.com/moby/buildkit/exporter"
+	containerimageexporter "github.com/moby/buildkit/exporter/containerimage"
 	localexporter "github.com/moby/buildkit/exporter/local"
 	tarexporter "github.com/moby/buildkit/exporter/tar"
 	"github.com/moby/buildkit/frontend"
@@ -38,6 +49,7 @@ import (
 	"github.com/moby/buildkit/source/local"
 	"github.com/moby/buildkit/util/archutil"
 	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/flightcontrol"
 	"github.com/moby/buildkit/util/leaseutil"
 	"github.com/moby/buildkit/util/progress"
 	"github.com/moby/buildkit/version"
@@ -66,6 +78,10 @@ const labelCreatedAt = "buildkit/createdat"
 type LayerAccess interface {
 	GetDiffIDs(ctx context.Context, key string) ([]layer.DiffID, error)
 	EnsureLayer(ctx context.Context, key string) ([]layer.DiffID, error)
+	// TarStream opens the uncompressed tar representation of the layer
+	// identified by diffID, for blobProvider to compress and ingest into
+	// the content store on demand.
+	TarStream(ctx context.Context, diffID layer.DiffID) (io.ReadCloser, error)
 }
 
 // Opt defines a structure for creating a worker.
@@ -87,6 +103,15 @@ type Opt struct {
 	Layers            LayerAccess
 	Platforms         []ocispec.Platform
 	CDIManager        *cdidevices.Manager
+	// RegistryHosts resolves per-ref registry hosts, mirrors, and auth; it
+	// is shared with the registry remote cache importer/exporter and the
+	// oci/image exporters so they honor the same configuration as normal
+	// image pulls.
+	RegistryHosts docker.RegistryHosts
+	// Applier and Differ back the ImageWriter used by the oci/image
+	// exporters to commit manifests and layer diffs into ContentStore.
+	Applier diff.Applier
+	Differ  diff.Comparer
 }
 
 // Worker is a local worker instance with dedicated snapshotter, cache, and so on.
@@ -94,6 +119,14 @@ type Opt struct {
 type Worker struct {
 	Opt
 	SourceManager *source.Manager
+
+	imageWriterOnce   sync.Once
+	imageWriterCached *containerimageexporter.ImageWriter
+	imageWriterErr    error
+
+	resolveFlight  flightcontrol.Group[*resolveConfigCacheEntry]
+	resolveCacheMu sync.Mutex
+	resolveCache   map[string]resolveConfigCacheEntry
 }
 
 var _ interface {
@@ -266,7 +299,7 @@ func (w *Worker) ResolveSourceMetadata(ctx context.Context, op *pb.SourceOp, opt
 		if opt.ImageOpt == nil {
 			opt.ImageOpt = &sourceresolver.ResolveImageOpt{}
 		}
-		dgst, config, err := w.ImageSource.ResolveImageConfig(ctx, idt.Reference.String(), opt, sm, g)
+		dgst, config, err := w.resolveImageConfig(ctx, idt.Reference.String(), opt, sm, g)
 		if err != nil {
 			return nil, err
 		}
@@ -309,7 +342,76 @@ func (w *Worker) ResolveOp(v solver.Vertex, s frontend.FrontendLLBBridge, sm *se
 
 // ResolveImageConfig returns image config for an image
 func (w *Worker) ResolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt, sm *session.Manager, g session.Group) (digest.Digest, []byte, error) {
-	return w.ImageSource.ResolveImageConfig(ctx, ref, opt, sm, g)
+	return w.resolveImageConfig(ctx, ref, opt, sm, g)
+}
+
+// resolveModePreferLocal is honored as the ResolveMode of opt.ImageOpt: when
+// set, and the ref already has a config cached locally, resolution is
+// satisfied entirely from the local image store/resolver cache with no
+// registry round-trip at all, regardless of TTL. Anything else
+// (the buildkit-defined "default"/"pullalways" modes) goes through the TTL
+// cache below as usual.
+const resolveModePreferLocal = "preferlocal"
+
+// resolveConfigCacheTTL bounds how long a successful (digest, config) result
+// for a given (ref, resolve mode) is reused before the next call goes back
+// to the registry; it exists so a build with many FROM/COPY --from=image
+// steps referencing the same tag doesn't re-resolve it on every vertex.
+const resolveConfigCacheTTL = 2 * time.Minute
+
+type resolveConfigCacheEntry struct {
+	digest digest.Digest
+	config []byte
+	cached time.Time
+}
+
+// resolveImageConfig memoizes ImageSource.ResolveImageConfig results for a
+// short TTL, deduplicating concurrent lookups for the same key via
+// resolveFlight so that N frontends resolving the same base image in
+// parallel cause one registry round-trip rather than N. A ref already
+// resolved in the local image store is served from there directly when the
+// caller's ResolveMode is resolveModePreferLocal, regardless of the cache.
+func (w *Worker) resolveImageConfig(ctx context.Context, ref string, opt sourceresolver.Opt, sm *session.Manager, g session.Group) (digest.Digest, []byte, error) {
+	mode := ""
+	if opt.ImageOpt != nil {
+		mode = opt.ImageOpt.ResolveMode
+	}
+
+	key := ref + "|" + mode
+
+	if mode == resolveModePreferLocal {
+		if dgst, config, ok := w.ImageSource.LocalImageConfig(ref); ok {
+			return dgst, config, nil
+		}
+	}
+
+	w.resolveCacheMu.Lock()
+	if e, ok := w.resolveCache[key]; ok && time.Since(e.cached) < resolveConfigCacheTTL {
+		w.resolveCacheMu.Unlock()
+		return e.digest, e.config, nil
+	}
+	w.resolveCacheMu.Unlock()
+
+	res, err := w.resolveFlight.Do(ctx, key, func(ctx context.Context) (*resolveConfigCacheEntry, error) {
+		dgst, config, err := w.ImageSource.ResolveImageConfig(ctx, ref, opt, sm, g)
+		if err != nil {
+			// A bad credential or a ref that's vanished shouldn't poison the
+			// cache for the TTL window - just don't cache it.
+			return nil, err
+		}
+		e := &resolveConfigCacheEntry{digest: dgst, config: config, cached: time.Now()}
+		w.resolveCacheMu.Lock()
+		if w.resolveCache == nil {
+			w.resolveCache = map[string]resolveConfigCacheEntry{}
+		}
+		w.resolveCache[key] = *e
+		w.resolveCacheMu.Unlock()
+		return e, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return res.digest, res.config, nil
 }
 
 // DiskUsage returns disk usage report
@@ -335,11 +437,82 @@ func (w *Worker) Exporter(name string, sm *session.Manager) (exporter.Exporter,
 		return tarexporter.New(tarexporter.Opt{
 			SessionManager: sm,
 		})
+	case client.ExporterOCI, client.ExporterImage:
+		iw, err := w.imageWriter()
+		if err != nil {
+			return nil, err
+		}
+		return containerimageexporter.New(containerimageexporter.Opt{
+			SessionManager: sm,
+			ImageWriter:    iw,
+			Variant:        name,
+			LeaseManager:   w.LeaseManager(),
+			RegistryHosts:  w.Opt.RegistryHosts,
+		})
 	default:
 		return nil, errors.Errorf("exporter %q could not be found", name)
 	}
 }
 
+// imageWriter builds (and caches) the containerimage.ImageWriter shared by
+// the oci and image exporters, so manifest and blob writes for
+// `--output type=oci` and `type=image,push=true` land in the same content
+// store (and under the same lease) as everything else the worker produces.
+func (w *Worker) imageWriter() (*containerimageexporter.ImageWriter, error) {
+	w.imageWriterOnce.Do(func() {
+		w.imageWriterCached, w.imageWriterErr = containerimageexporter.NewImageWriter(containerimageexporter.WriterOpt{
+			Snapshotter:  w.Opt.Snapshotter,
+			ContentStore: w.ContentStore(),
+			Applier:      w.Opt.Applier,
+			Differ:       w.Opt.Differ,
+		})
+	})
+	return w.imageWriterCached, w.imageWriterErr
+}
+
+// ResolveCacheExporterFunc returns the remote cache exporter for typ
+// ("registry", "inline", "local", "gha", or "s3"), so that
+// `--cache-to=type=<typ>,...` works against the embedded builder. Other
+// types are left to the controller's own defaults. sm is the same
+// session.Manager the solve request is using, so per-ref registry hosts,
+// mirrors, and auth are honored the same way they are for a normal image
+// pull, and so the "local" exporter can stream its cache blobs back to the
+// client session the same way `docker buildx build --cache-to=type=local`
+// does.
+func (w *Worker) ResolveCacheExporterFunc(typ string, sm *session.Manager) (remotecache.ResolveCacheExporterFunc, bool) {
+	switch typ {
+	case "registry":
+		return registryremotecache.ResolveCacheExporterFunc(sm, w.Opt.RegistryHosts), true
+	case "inline":
+		return inlineremotecache.ResolveCacheExporterFunc(), true
+	case "local":
+		return localremotecache.ResolveCacheExporterFunc(sm), true
+	case "gha":
+		return gharemotecache.ResolveCacheExporterFunc(), true
+	case "s3":
+		return s3remotecache.ResolveCacheExporterFunc(), true
+	default:
+		return nil, false
+	}
+}
+
+// ResolveCacheImporterFunc returns the remote cache importer for typ,
+// mirroring ResolveCacheExporterFunc above.
+func (w *Worker) ResolveCacheImporterFunc(typ string, sm *session.Manager) (remotecache.ResolveCacheImporterFunc, bool) {
+	switch typ {
+	case "registry":
+		return registryremotecache.ResolveCacheImporterFunc(sm, w.ContentStore().Bases(), w.Opt.RegistryHosts), true
+	case "local":
+		return localremotecache.ResolveCacheImporterFunc(sm), true
+	case "gha":
+		return gharemotecache.ResolveCacheImporterFunc(), true
+	case "s3":
+		return s3remotecache.ResolveCacheImporterFunc(), true
+	default:
+		return nil, false
+	}
+}
+
 // GetRemotes returns the remote snapshot references given a local reference
 func (w *Worker) GetRemotes(ctx context.Context, ref cache.ImmutableRef, createIfNeeded bool, _ cacheconfig.RefConfig, all bool, s session.Group) ([]*solver.Remote, error) {
 	if ref == nil {
@@ -366,20 +539,137 @@ func (w *Worker) GetRemotes(ctx context.Context, ref cache.ImmutableRef, createI
 	}
 
 	descriptors := make([]ocispec.Descriptor, len(diffIDs))
-	for i, dgst := range diffIDs {
-		descriptors[i] = ocispec.Descriptor{
-			MediaType: c8dimages.MediaTypeDockerSchema2Layer,
-			Digest:    dgst,
-			Size:      -1,
+	for i, diffID := range diffIDs {
+		desc, err := w.blobProvider().ensureBlob(ctx, diffID)
+		if err != nil {
+			return nil, err
 		}
+		descriptors[i] = desc
 	}
 
 	return []*solver.Remote{{
 		Descriptors: descriptors,
-		Provider:    &emptyProvider{},
+		Provider:    w.blobProvider(),
 	}}, nil
 }
 
+func (w *Worker) blobProvider() *layerBlobProvider {
+	return &layerBlobProvider{w: w}
+}
+
+// layerBlobProvider is a content.Provider that serves layer blobs named by a
+// diffID-annotated descriptor straight out of moby's layer store.
+// GetRemotes compresses and ingests each layer into the worker's content
+// store (under a lease, so they survive until the exporter/cache-export
+// using them is done) while building its descriptors, and ReaderAt does the
+// same again for a blob no longer in the store. This lets remotes returned from
+// GetRemotes actually be read back - for push, for remote cache export, and
+// for cross-worker transfer - rather than only round-tripping diffIDs.
+type layerBlobProvider struct {
+	w *Worker
+}
+
+func (p *layerBlobProvider) ReaderAt(ctx context.Context, desc ocispec.Descriptor) (content.ReaderAt, error) {
+	if _, err := p.w.ContentStore().Info(ctx, desc.Digest); err != nil {
+		if _, ierr := p.ensureBlobByDigest(ctx, desc); ierr != nil {
+			return nil, ierr
+		}
+	}
+	return p.w.ContentStore().ReaderAt(ctx, desc)
+}
+
+func (p *layerBlobProvider) Info(ctx context.Context, dgst digest.Digest) (content.Info, error) {
+	info, err := p.w.ContentStore().Info(ctx, dgst)
+	if err != nil {
+		return content.Info{}, errors.Wrapf(cerrdefs.ErrNotFound, "blob %s: %s", dgst, err)
+	}
+	return info, nil
+}
+
+// ensureBlob compresses and ingests the layer identified by diffID into the
+// content store if it isn't already there, returning a fully-populated
+// descriptor (real Size and the containerd.io/uncompressed annotation set
+// to diffID) rather than the Size: -1 placeholder GetRemotes used to hand
+// out.
+func (p *layerBlobProvider) ensureBlob(ctx context.Context, diffID layer.DiffID) (ocispec.Descriptor, error) {
+	lease, err := leaseutil.WithLease(ctx, p.w.LeaseManager(), leaseutil.MakeTemporary)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	ctx = lease
+
+	rc, err := p.w.Layers.TarStream(ctx, diffID)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer rc.Close()
+
+	ref := "blob-" + diffID.String()
+	w, err := p.w.ContentStore().Writer(ctx, content.WithRef(ref))
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer w.Close()
+
+	// The descriptor this returns is labeled MediaTypeDockerSchema2Layer
+	// (application/vnd.docker.image.rootfs.diff.tar.gzip), so the blob
+	// actually ingested into the content store has to be the gzip-compressed
+	// bytes, not the raw tar rc streams - diffID (the uncompressed digest)
+	// is kept only as the containerd.io/uncompressed annotation, same as any
+	// other compressed layer blob.
+	dgstr := digest.Canonical.Digester()
+	counter := &writeCounter{}
+	gw := gzip.NewWriter(io.MultiWriter(w, dgstr.Hash(), counter))
+	if _, err := io.Copy(gw, rc); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := gw.Close(); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	dgst := dgstr.Digest()
+	n := counter.n
+	if err := w.Commit(ctx, n, dgst, content.WithLabels(map[string]string{
+		"containerd.io/uncompressed": diffID.String(),
+	})); err != nil && !cerrdefs.IsAlreadyExists(err) {
+		return ocispec.Descriptor{}, err
+	}
+
+	return ocispec.Descriptor{
+		MediaType: c8dimages.MediaTypeDockerSchema2Layer,
+		Digest:    dgst,
+		Size:      n,
+		Annotations: map[string]string{
+			"containerd.io/uncompressed": diffID.String(),
+		},
+	}, nil
+}
+
+// ensureBlobByDigest is used from ReaderAt, where only the (already
+// compressed-blob-digest) descriptor is available; it recovers the diffID
+// from the descriptor's own annotation rather than re-deriving it.
+func (p *layerBlobProvider) ensureBlobByDigest(ctx context.Context, desc ocispec.Descriptor) (ocispec.Descriptor, error) {
+	diffIDStr := desc.Annotations["containerd.io/uncompressed"]
+	if diffIDStr == "" {
+		return ocispec.Descriptor{}, errors.Errorf("descriptor %s missing containerd.io/uncompressed annotation", desc.Digest)
+	}
+	dgst, err := digest.Parse(diffIDStr)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return p.ensureBlob(ctx, layer.DiffID(dgst))
+}
+
+// writeCounter is an io.Writer that only tracks how many bytes have been
+// written to it, used alongside the content writer and digester in
+// ensureBlob's io.MultiWriter so the compressed blob's size is known
+// without a second pass over the data.
+type writeCounter struct{ n int64 }
+
+func (c *writeCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
 // PruneCacheMounts removes the current cache snapshots for specified IDs
 func (w *Worker) PruneCacheMounts(ctx context.Context, ids map[string]bool) error {
 	mu := mounts.CacheMountsLocker()
@@ -595,13 +885,3 @@ func oneOffProgress(ctx context.Context, id string) func(err error) error {
 		return err
 	}
 }
-
-type emptyProvider struct{}
-
-func (p *emptyProvider) ReaderAt(ctx context.Context, dec ocispec.Descriptor) (content.ReaderAt, error) {
-	return nil, errors.Errorf("ReaderAt not implemented for empty provider")
-}
-
-func (p *emptyProvider) Info(ctx context.Context, d digest.Digest) (content.Info, error) {
-	return content.Info{}, errors.Wrapf(cerrdefs.ErrNotImplemented, "Info not implemented for empty provider")
-}
@@ -0,0 +1,222 @@
+//go:build linux
+
+package cni
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containernetworking/cni/libcni"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containerd/log"
+	"github.com/moby/moby/v2/daemon/libnetwork/driverapi"
+)
+
+// argsOption/capabilitiesOption are endpoint options a caller can set (via
+// EndpointOptions on network connect) to pass CNI_ARGS-style key/value pairs
+// and plugin capability arguments through to ADD/DEL, the same two
+// extension points any CNI runtime (including kubelet) exposes per
+// container.
+const (
+	argsOption         = "com.docker.network.cni.args"
+	capabilitiesOption = "com.docker.network.cni.capabilities"
+)
+
+// endpoint tracks enough state about one CNI ADD to issue the matching DEL
+// later and to answer EndpointOperInfo in the meantime.
+type endpoint struct {
+	id  string
+	nid string
+
+	ifName    string
+	sandboxID string
+
+	runtimeConf *libcni.RuntimeConf
+	result      *current.Result
+}
+
+// CreateEndpoint reserves the endpoint's identity; the CNI ADD itself only
+// happens in Join, once a sandbox (netns) exists for the plugin to attach
+// into. This mirrors CNI's own contract: ADD takes a netns path, which
+// libnetwork doesn't have until Join.
+func (d *driver) CreateEndpoint(ctx context.Context, nid, eid string, ifInfo driverapi.InterfaceInfo, epOptions map[string]interface{}) error {
+	n, err := d.network(nid)
+	if err != nil {
+		return err
+	}
+
+	ep := &endpoint{id: eid, nid: nid}
+
+	d.mu.Lock()
+	n.endpoints[eid] = ep
+	d.mu.Unlock()
+
+	return nil
+}
+
+// DeleteEndpoint forgets the endpoint. If Join was never called (and so no
+// CNI ADD was ever issued) this is a no-op beyond bookkeeping.
+func (d *driver) DeleteEndpoint(nid, eid string) error {
+	n, err := d.network(nid)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	delete(n.endpoints, eid)
+	d.mu.Unlock()
+	return nil
+}
+
+// EndpointOperInfo returns the CNI result's raw fields for inspection, e.g.
+// `docker network inspect`'s per-endpoint data.
+func (d *driver) EndpointOperInfo(nid, eid string) (map[string]interface{}, error) {
+	n, err := d.network(nid)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	ep, ok := n.endpoints[eid]
+	d.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("cni driver: endpoint %q not found", eid)
+	}
+	if ep.result == nil {
+		return map[string]interface{}{}, nil
+	}
+	return map[string]interface{}{
+		"cni.result": ep.result.String(),
+	}, nil
+}
+
+// Join runs CNI ADD against the CNI config bound to nid, with the
+// container's network namespace as the CNI runtime's netns, then translates
+// the resulting routes and gateway into jinfo so libnetwork can wire them
+// into the sandbox the same way it would for any other driver's Join (see
+// applyCNIResult for why IPs and DNS are not passed on).
+func (d *driver) Join(ctx context.Context, nid, eid string, sboxKey string, jinfo driverapi.JoinInfo, options map[string]interface{}) error {
+	n, err := d.network(nid)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	ep, ok := n.endpoints[eid]
+	d.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("cni driver: endpoint %q not found", eid)
+	}
+
+	ifName := "eth0"
+	rt := &libcni.RuntimeConf{
+		ContainerID:    eid,
+		NetNS:          sboxKey,
+		IfName:         ifName,
+		Args:           parseCNIArgs(options[argsOption]),
+		CapabilityArgs: parseCNICapabilities(options[capabilitiesOption]),
+	}
+
+	resultIface, err := d.cniConf.AddNetworkList(ctx, n.conf, rt)
+	if err != nil {
+		return fmt.Errorf("cni driver: ADD failed for endpoint %.7s: %w", eid, err)
+	}
+	result, err := current.GetResult(resultIface)
+	if err != nil {
+		return fmt.Errorf("cni driver: unexpected CNI result for endpoint %.7s: %w", eid, err)
+	}
+
+	ep.ifName = ifName
+	ep.sandboxID = sboxKey
+	ep.runtimeConf = rt
+	ep.result = result
+
+	if err := applyCNIResult(result, jinfo); err != nil {
+		return err
+	}
+
+	log.G(ctx).Infof("cni driver: endpoint %.7s joined via CNI network %q", eid, n.confName)
+	return nil
+}
+
+// Leave runs CNI DEL for the endpoint's earlier ADD. CNI requires DEL to be
+// called with the exact same RuntimeConf as the matching ADD, which is why
+// Join stashes it on the endpoint rather than recomputing it here.
+func (d *driver) Leave(nid, eid string) error {
+	n, err := d.network(nid)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	ep, ok := n.endpoints[eid]
+	d.mu.Unlock()
+	if !ok || ep.runtimeConf == nil {
+		return nil
+	}
+
+	if err := d.cniConf.DelNetworkList(context.Background(), n.conf, ep.runtimeConf); err != nil {
+		return fmt.Errorf("cni driver: DEL failed for endpoint %.7s: %w", eid, err)
+	}
+
+	ep.runtimeConf = nil
+	ep.result = nil
+	return nil
+}
+
+// applyCNIResult copies the routes and gateway from a CNI ADD result into
+// jinfo. Unlike most libnetwork drivers, this one doesn't create the
+// container-side interface or assign its address itself: the CNI plugin
+// already did both, directly inside the sandbox netns, as part of ADD. So
+// there's nothing to feed back through InterfaceInfo - only the
+// route/gateway wiring JoinInfo exposes is relevant here.
+//
+// DNS (result.DNS) is intentionally not wired up yet: resolv.conf
+// management in this driver would need to interact with the container's
+// already-templated resolv.conf rather than overwrite it outright, which is
+// left as follow-up.
+func applyCNIResult(result *current.Result, jinfo driverapi.JoinInfo) error {
+	var sawDefaultRoute bool
+	for _, route := range result.Routes {
+		if route.Dst.IP == nil || (route.Dst.IP.IsUnspecified() && route.GW != nil) {
+			sawDefaultRoute = true
+			if err := jinfo.SetGateway(route.GW); err != nil {
+				return fmt.Errorf("setting gateway %s: %w", route.GW, err)
+			}
+			continue
+		}
+		if err := jinfo.AddStaticRoute(&route.Dst, driverapi.NEXTHOP, route.GW); err != nil {
+			return fmt.Errorf("adding route %s via %s: %w", route.Dst.String(), route.GW, err)
+		}
+	}
+
+	if !sawDefaultRoute {
+		for _, ip := range result.IPs {
+			if ip.Gateway != nil {
+				if err := jinfo.SetGateway(ip.Gateway); err != nil {
+					return fmt.Errorf("setting gateway %s: %w", ip.Gateway, err)
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseCNIArgs(v interface{}) [][2]string {
+	m, _ := v.(map[string]string)
+	if len(m) == 0 {
+		return nil
+	}
+	args := make([][2]string, 0, len(m))
+	for k, val := range m {
+		args = append(args, [2]string{k, val})
+	}
+	return args
+}
+
+func parseCNICapabilities(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
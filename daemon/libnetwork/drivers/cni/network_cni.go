@@ -0,0 +1,120 @@
+//go:build linux
+
+package cni
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containerd/log"
+	"github.com/moby/moby/v2/daemon/libnetwork/driverapi"
+)
+
+// configOption is the --opt key used on `docker network create --driver
+// cni` to select which CNI network config (by its CNI `name`, not its file
+// name) this docker network maps to, e.g.
+// `docker network create -d cni --opt config=mynet ...`.
+const configOption = "config"
+
+// network is one docker network backed by a single CNI network config.
+type network struct {
+	id       string
+	confName string
+	conf     *libcni.NetworkConfigList
+
+	endpoints map[string]*endpoint
+}
+
+// CreateNetwork loads the CNI network config named by the "config" driver
+// option from d.confDir and associates it with nid. It does not itself
+// invoke any CNI plugin - ADD only happens per-endpoint, in Join, matching
+// how CNI runtimes normally drive plugins per-container
+// rather than per-network.
+func (d *driver) CreateNetwork(ctx context.Context, nid string, options map[string]interface{}, nInfo driverapi.NetworkInfo, ipV4Data, ipV6Data []driverapi.IPAMData) error {
+	confName, _ := options[configOption].(string)
+	if confName == "" {
+		return fmt.Errorf("cni driver: --opt config=<cni-network-name> is required")
+	}
+
+	conf, err := d.loadConfig(confName)
+	if err != nil {
+		return fmt.Errorf("cni driver: loading CNI config %q: %w", confName, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.networks[nid] = &network{
+		id:        nid,
+		confName:  confName,
+		conf:      conf,
+		endpoints: map[string]*endpoint{},
+	}
+
+	log.G(ctx).Infof("cni driver: network %s bound to CNI config %q", nid, confName)
+	return nil
+}
+
+// DeleteNetwork forgets the docker network -> CNI config mapping for nid.
+// Any endpoints still attached at this point are a caller bug (libnetwork
+// guarantees endpoints are deleted before their network); it's logged
+// rather than treated as fatal, matching how DeleteNetwork is implemented
+// in the other libnetwork drivers in this tree.
+func (d *driver) DeleteNetwork(nid string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, ok := d.networks[nid]
+	if !ok {
+		return fmt.Errorf("cni driver: network %q not found", nid)
+	}
+	if len(n.endpoints) > 0 {
+		log.G(context.TODO()).Warnf("cni driver: deleting network %s with %d endpoint(s) still attached", nid, len(n.endpoints))
+	}
+	delete(d.networks, nid)
+	return nil
+}
+
+func (d *driver) network(nid string) (*network, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n, ok := d.networks[nid]
+	if !ok {
+		return nil, fmt.Errorf("cni driver: network %q not found", nid)
+	}
+	return n, nil
+}
+
+// loadConfig looks up confName among the *.conflist/*.conf/*.json files in
+// d.confDir, the same discovery libcni itself does for a CNI-native
+// runtime, and returns the first config whose Name matches (not its file
+// name - CNI config file names are purely conventional).
+func (d *driver) loadConfig(confName string) (*libcni.NetworkConfigList, error) {
+	files, err := libcni.ConfFiles(d.confDir, []string{".conf", ".conflist", ".json"})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		var confList *libcni.NetworkConfigList
+		if filepath.Ext(f) == ".conflist" {
+			confList, err = libcni.ConfListFromFile(f)
+		} else {
+			var conf *libcni.NetworkConfig
+			conf, err = libcni.ConfFromFile(f)
+			if err == nil {
+				confList, err = libcni.ConfListFromConf(conf)
+			}
+		}
+		if err != nil {
+			log.G(context.TODO()).WithError(err).Warnf("cni driver: skipping unreadable CNI config %s", f)
+			continue
+		}
+		if confList.Name == confName {
+			return confList, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no CNI network config named %q found in %s", confName, d.confDir)
+}
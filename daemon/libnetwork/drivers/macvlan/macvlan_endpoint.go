@@ -5,6 +5,7 @@ package macvlan
 import (
 	"context"
 	"fmt"
+	"regexp"
 
 	"github.com/containerd/log"
 	"github.com/moby/moby/v2/daemon/libnetwork/driverapi"
@@ -15,6 +16,41 @@ import (
 	"github.com/moby/moby/v2/errdefs"
 )
 
+// sysctlOption and mtuOption name per-endpoint options a caller could set
+// (via EndpointOptions on network connect) to reach past CreateEndpoint's
+// defaults: sysctlOption would take net.ipv{4,6}.conf.IFNAME.* sysctls to
+// apply once the macvlan slave is named inside the container netns, and
+// mtuOption would override the slave's MTU, which otherwise inherits the
+// parent link's. Neither is actually applied anywhere yet - this driver has
+// no Join to apply them from - so CreateEndpoint rejects both rather than
+// accept and persist options that would silently have no effect.
+const (
+	sysctlOption = "com.docker.network.endpoint.sysctls"
+	mtuOption    = "com.docker.network.endpoint.mtu"
+)
+
+// endpointSysctlKey matches the net.ipv4.conf.IFNAME.*/net.ipv6.conf.IFNAME.*
+// keys sysctlOption accepts, capturing the per-interface suffix so it can be
+// checked against endpointSysctlAllowlist.
+var endpointSysctlKey = regexp.MustCompile(`^net\.ipv[46]\.conf\.IFNAME\.([a-z0-9_]+)$`)
+
+// endpointSysctlAllowlist is the set of per-interface sysctl suffixes
+// sysctlOption accepts. It exists to keep a container-supplied sysctl
+// option scoped to its own interface instead of letting it reach host-wide
+// settings (e.g. an "all"/"default" conf entry would apply beyond the
+// container's own macvlan slave).
+var endpointSysctlAllowlist = map[string]bool{
+	"accept_dad":   true,
+	"disable_ipv6": true,
+	"autoconf":     true,
+	"accept_ra":    true,
+	"forwarding":   true,
+	"arp_ignore":   true,
+	"arp_announce": true,
+	"proxy_arp":    true,
+	"rp_filter":    true,
+}
+
 // CreateEndpoint assigns the mac, ip and endpoint id for the new container
 func (d *driver) CreateEndpoint(ctx context.Context, nid, eid string, ifInfo driverapi.InterfaceInfo, epOptions map[string]interface{}) error {
 	if err := validateID(nid, eid); err != nil {
@@ -54,6 +90,33 @@ func (d *driver) CreateEndpoint(ctx context.Context, nid, eid string, ifInfo dri
 		}
 	}
 
+	sysctls, err := parseEndpointSysctls(epOptions[sysctlOption])
+	if err != nil {
+		return err
+	}
+	if len(sysctls) > 0 {
+		// applyEndpointSysctls has no caller: this driver has no Join that
+		// moves the macvlan slave into the container netns and applies it
+		// there. Accepting and persisting sysctls anyway would make
+		// CreateEndpoint lie about taking effect, so refuse until the apply
+		// path exists instead of silently doing nothing.
+		return errdefs.NotImplemented(fmt.Errorf("%s is not yet applied by this driver", sysctlOption))
+	}
+	ep.sysctls = sysctls
+
+	if opt, ok := epOptions[mtuOption]; ok {
+		mtu, ok := opt.(int)
+		if !ok || mtu <= 0 {
+			return errdefs.InvalidParameter(fmt.Errorf("%s must be a positive integer, got %v", mtuOption, opt))
+		}
+		if err := validateEndpointMTU(n, mtu); err != nil {
+			return errdefs.InvalidParameter(err)
+		}
+		// Same as sysctls above: nothing applies this override to the real
+		// interface yet, so reject it rather than accept it for no effect.
+		return errdefs.NotImplemented(fmt.Errorf("%s is not yet applied by this driver", mtuOption))
+	}
+
 	if err := d.storeUpdate(ep); err != nil {
 		return fmt.Errorf("failed to save macvlan endpoint %.7s to store: %v", ep.id, err)
 	}
@@ -90,3 +153,49 @@ func (d *driver) DeleteEndpoint(nid, eid string) error {
 
 	return nil
 }
+
+// parseEndpointSysctls validates an epOptions[sysctlOption] value, rejecting
+// any key that doesn't match endpointSysctlKey or whose suffix isn't in
+// endpointSysctlAllowlist. A nil/missing option returns a nil map rather
+// than an error.
+func parseEndpointSysctls(opt interface{}) (map[string]string, error) {
+	if opt == nil {
+		return nil, nil
+	}
+	raw, ok := opt.(map[string]string)
+	if !ok {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("%s must be a map[string]string", sysctlOption))
+	}
+	sysctls := make(map[string]string, len(raw))
+	for k, v := range raw {
+		m := endpointSysctlKey.FindStringSubmatch(k)
+		if m == nil {
+			return nil, errdefs.InvalidParameter(fmt.Errorf("%s key %q must match net.ipv4.conf.IFNAME.* or net.ipv6.conf.IFNAME.*", sysctlOption, k))
+		}
+		if !endpointSysctlAllowlist[m[1]] {
+			return nil, errdefs.InvalidParameter(fmt.Errorf("%s key %q is not in the allowed set for macvlan endpoints", sysctlOption, k))
+		}
+		sysctls[k] = v
+	}
+	return sysctls, nil
+}
+
+// validateEndpointMTU rejects an MTU override that exceeds the macvlan
+// slave's parent link MTU: a macvlan slave can't carry a larger MTU than
+// the link it rides on.
+func validateEndpointMTU(n *network, mtu int) error {
+	if n.config.Parent == "" {
+		return nil
+	}
+	parent, err := ns.NlHandle().LinkByName(n.config.Parent)
+	if err != nil {
+		// Parent isn't resolvable from here (e.g. restore before the host
+		// network stack is up); defer the check to Join instead of
+		// failing CreateEndpoint over it.
+		return nil
+	}
+	if parentMTU := parent.Attrs().MTU; mtu > parentMTU {
+		return fmt.Errorf("endpoint mtu %d exceeds parent link %q mtu %d", mtu, n.config.Parent, parentMTU)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package overlay
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/Microsoft/hcsshim"
+	"github.com/containerd/log"
+)
+
+// restoreNetworkEndpoints repopulates n.endpoints from HNS's own endpoint
+// list, matched by this network's HNS ID. Without this, every endpoint that
+// was live before a daemon restart is dropped from libnetwork's in-memory
+// state even though HNS (and the containers using it) still has it, forcing
+// a destroy-and-recreate cycle that breaks existing container connectivity.
+func (n *network) restoreNetworkEndpoints() error {
+	hnsEndpoints, err := hcsshim.HNSListEndpointRequest()
+	if err != nil {
+		return fmt.Errorf("failed to list HNS endpoints: %w", err)
+	}
+
+	var restored int
+	for i := range hnsEndpoints {
+		he := &hnsEndpoints[i]
+		if he.VirtualNetwork != n.hnsID {
+			continue
+		}
+
+		ep, err := convertToOverlayEndpoint(he)
+		if err != nil {
+			log.G(context.TODO()).WithError(err).Warnf("overlay: skipping unrecognized HNS endpoint %s on network %s", he.Id, n.id)
+			continue
+		}
+
+		n.driver.Lock()
+		n.endpoints[ep.id] = ep
+		n.driver.Unlock()
+		restored++
+	}
+
+	log.G(context.TODO()).Infof("overlay: restored %d endpoint(s) for network %s from HNS", restored, n.id)
+	return nil
+}
+
+// convertToOverlayEndpoint builds the endpoint state restoreNetworkEndpoints
+// needs out of a raw HNS endpoint, mirroring convertToOverlayNetwork's role
+// for networks.
+func convertToOverlayEndpoint(he *hcsshim.HNSEndpoint) (*endpoint, error) {
+	mac, err := net.ParseMAC(he.MacAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC address %q: %w", he.MacAddress, err)
+	}
+
+	ep := &endpoint{
+		id:        he.Id,
+		profileID: he.Id,
+		mac:       mac,
+	}
+
+	if he.IPAddress != nil {
+		ep.addr = &net.IPNet{
+			IP:   he.IPAddress,
+			Mask: net.CIDRMask(32, 32),
+		}
+	}
+	if he.IPv6Address != nil {
+		ep.addrv6 = &net.IPNet{
+			IP:   he.IPv6Address,
+			Mask: net.CIDRMask(128, 128),
+		}
+	}
+
+	return ep, nil
+}
+
+// reconcileHNSEndpoints runs once at startup, after every known network has
+// had a chance to restore its endpoints, and logs how state actually came
+// out: endpoints HNS still has that got matched back into a docker network
+// ("salvaged"), versus endpoints whose HNS network isn't one of this
+// driver's overlay networks ("orphaned"). Every endpoint HNS lists is
+// counted, so endpoints of non-overlay HNS networks (e.g. nat) are counted
+// as orphaned too. It's diagnostic only, but it gives operators visibility
+// into what survived a restart versus what had to be rebuilt from scratch.
+func (d *driver) reconcileHNSEndpoints() {
+	hnsEndpoints, err := hcsshim.HNSListEndpointRequest()
+	if err != nil {
+		log.G(context.TODO()).WithError(err).Warn("overlay: failed to list HNS endpoints for reconciliation")
+		return
+	}
+
+	d.Lock()
+	networksByHNSID := make(map[string]*network, len(d.networks))
+	for _, n := range d.networks {
+		networksByHNSID[n.hnsID] = n
+	}
+	d.Unlock()
+
+	var salvaged, orphaned int
+	for i := range hnsEndpoints {
+		he := &hnsEndpoints[i]
+		n, ok := networksByHNSID[he.VirtualNetwork]
+		if !ok {
+			orphaned++
+			log.G(context.TODO()).Warnf("overlay: HNS endpoint %s belongs to network %s, which is not a known overlay network", he.Id, he.VirtualNetwork)
+			continue
+		}
+
+		n.driver.Lock()
+		_, tracked := n.endpoints[he.Id]
+		n.driver.Unlock()
+		if tracked {
+			salvaged++
+		}
+	}
+
+	log.G(context.TODO()).Infof("overlay: HNS endpoint reconciliation complete: %d salvaged, %d orphaned", salvaged, orphaned)
+}
@@ -55,13 +55,13 @@ func (d *driver) restoreHNSNetworks() error {
 		n := d.convertToOverlayNetwork(&v)
 		d.addNetwork(n)
 
-		//
-		// We assume that any network will be recreated on daemon restart
-		// and therefore don't restore hns endpoints for now
-		//
-		// n.restoreNetworkEndpoints()
+		if err := n.restoreNetworkEndpoints(); err != nil {
+			log.G(context.TODO()).WithError(err).Warnf("Failed to restore HNS endpoints for overlay network %s", n.id)
+		}
 	}
 
+	d.reconcileHNSEndpoints()
+
 	return nil
 }
 
@@ -0,0 +1,117 @@
+//go:build linux
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/containerd/log"
+	mounttypes "github.com/moby/moby/api/types/mount"
+	"golang.org/x/sys/unix"
+)
+
+// openTreeSupported caches whether the kernel supports open_tree(2), since a
+// single ENOSYS probe is enough for the lifetime of the daemon.
+var (
+	openTreeOnce      sync.Once
+	openTreeSupported bool
+)
+
+// openTreeBindSource clones the mount tree rooted at source in the daemon's
+// own (initial) user namespace via open_tree(OPEN_TREE_CLONE), returning a
+// detached mount fd handed to the runtime as /proc/<daemon-pid>/fd/N - the
+// magic-symlink form that dereferences through this (still running) daemon
+// process, rather than /proc/self/fd/N, which would resolve against
+// whichever process opens it and is meaningless to anything but the daemon
+// itself. The fd must stay open (the caller is expected to defer the
+// returned cleanup until after the runtime has mounted it) for that path to
+// keep resolving.
+//
+// This lets us attach bind-mount sources whose parent directories are not
+// searchable by the (possibly userns-remapped) container root, without
+// requiring the usual chmod/chown dance on those intermediate directories.
+// It is only used for mounts that actually need the detached tree - an
+// idmapped mount, or a kernel-enforced recursive read-only mount - callers
+// must fall back to the existing path-based Setup() otherwise, as well as
+// when ok is false, which happens on kernels older than 5.2 or whenever
+// open_tree returns ENOSYS. For a ReadOnlyForceRecursive mount those cases
+// return an error instead.
+func (daemon *Daemon) openTreeBindSource(ctx context.Context, source string, bindOpts *mounttypes.BindOptions) (procPath string, cleanup func(context.Context) error, ok bool, err error) {
+	openTreeOnce.Do(func() {
+		fd, probeErr := unix.OpenTree(-1, os.DevNull, unix.OPEN_TREE_CLONE)
+		if probeErr == nil {
+			unix.Close(fd)
+			openTreeSupported = true
+		} else if probeErr != unix.ENOSYS {
+			// Any error other than ENOSYS means the syscall exists but our
+			// probe arguments were rejected; that's still good enough to
+			// know open_tree is implemented.
+			openTreeSupported = true
+		}
+	})
+	forceRecursiveRO := bindOpts != nil && bindOpts.ReadOnlyForceRecursive
+	if !openTreeSupported {
+		if forceRecursiveRO {
+			return "", nil, false, fmt.Errorf("ReadOnlyForceRecursive requires open_tree(2) support (kernel >= 5.2), which is not available")
+		}
+		return "", nil, false, nil
+	}
+
+	fd, err := unix.OpenTree(unix.AT_FDCWD, source, unix.OPEN_TREE_CLONE|unix.OPEN_TREE_CLOEXEC|unix.AT_RECURSIVE)
+	if err != nil {
+		if err == unix.ENOSYS {
+			openTreeSupported = false
+			if forceRecursiveRO {
+				return "", nil, false, fmt.Errorf("ReadOnlyForceRecursive requires open_tree(2) support (kernel >= 5.2), which is not available")
+			}
+			return "", nil, false, nil
+		}
+		return "", nil, false, fmt.Errorf("open_tree(%s): %w", source, err)
+	}
+
+	f := os.NewFile(uintptr(fd), source)
+	cleanup = func(ctx context.Context) error {
+		if err := f.Close(); err != nil {
+			log.G(ctx).WithError(err).WithField("source", source).Warn("failed to close detached mount fd")
+			return err
+		}
+		return nil
+	}
+
+	if daemon.shouldIDMapMount(bindOpts) {
+		if err := idMapMount(ctx, f, daemon.idMapping); err != nil {
+			cleanup(ctx)
+			return "", nil, false, fmt.Errorf("idmap bind mount source %s: %w", source, err)
+		}
+	}
+
+	if forceRecursiveRO {
+		if err := enforceRecursiveReadOnly(f); err != nil {
+			cleanup(ctx)
+			return "", nil, false, fmt.Errorf("enforcing recursive read-only on %s: %w", source, err)
+		}
+	}
+
+	return fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd()), cleanup, true, nil
+}
+
+// enforceRecursiveReadOnly applies MOUNT_ATTR_RDONLY to every submount of
+// the detached mount held by f, so that ReadOnlyForceRecursive is a kernel
+// guarantee rather than something that depends on the runtime also doing
+// the right thing. It requires a kernel new enough to support
+// mount_setattr(AT_RECURSIVE) (>= 5.12); any failure - whether the syscall
+// is unsupported or a submount rejects the attribute change (e.g. it's
+// locked) - is returned so the caller fails the container start instead of
+// silently downgrading to a non-recursive read-only mount.
+func enforceRecursiveReadOnly(f *os.File) error {
+	attr := unix.MountAttr{
+		Attr_set: unix.MOUNT_ATTR_RDONLY,
+	}
+	if err := unix.MountSetattr(int(f.Fd()), "", unix.AT_EMPTY_PATH|unix.AT_RECURSIVE, &attr); err != nil {
+		return fmt.Errorf("mount_setattr(AT_RECURSIVE, MOUNT_ATTR_RDONLY): %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+//go:build linux
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/containerd/log"
+	mounttypes "github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/v2/daemon/internal/idtools"
+	"github.com/moby/moby/v2/pkg/reexec"
+	"golang.org/x/sys/unix"
+)
+
+const idMapMountHelperName = "docker-idmap-mount-helper"
+
+func init() {
+	reexec.Register(idMapMountHelperName, idMapMountHelperMain)
+}
+
+var (
+	idMapMountOnce      sync.Once
+	idMapMountSupported bool
+)
+
+// shouldIDMapMount decides whether a bind mount should be attached to the
+// container via an idmapped mount instead of the fd returned by open_tree
+// being used as-is.
+//
+// "auto" (the default when BindOptions is nil, matching existing untagged
+// bind mounts) enables idmapping whenever userns-remap is configured and the
+// kernel/filesystem combination supports it; "always" also enables it
+// without userns-remap, but with userns-remap it is skipped like "auto"
+// when the kernel lacks idmapped-mount support, and a failure to apply it
+// falls back to the path-based mount in setupMounts rather than failing;
+// "never" keeps the historical behavior of relying on pre-chowned host
+// directories or volume driver remapping.
+func (daemon *Daemon) shouldIDMapMount(bindOpts *mounttypes.BindOptions) bool {
+	mode := mounttypes.IDMapModeAuto
+	if bindOpts != nil && bindOpts.IDMapped != "" {
+		mode = bindOpts.IDMapped
+	}
+	if mode == mounttypes.IDMapModeNever {
+		return false
+	}
+
+	uid, gid := daemon.idMapping.RootPair()
+	if uid == 0 && gid == 0 {
+		// Not running with userns-remap: there is nothing to remap.
+		return mode == mounttypes.IDMapModeAlways
+	}
+
+	return probeIDMapMountSupported()
+}
+
+// probeIDMapMountSupported does a one-time check for MOUNT_ATTR_IDMAP support
+// by attempting mount_setattr on a detached mount of an anonymous tmpfs-free
+// target ("/"); the kernel validates the attribute mask before anything else,
+// so EINVAL/ENOSYS reliably distinguishes "not supported" from "supported,
+// but this particular userns fd/mount was rejected".
+func probeIDMapMountSupported() bool {
+	idMapMountOnce.Do(func() {
+		fd, err := unix.OpenTree(unix.AT_FDCWD, "/", unix.OPEN_TREE_CLONE|unix.OPEN_TREE_CLOEXEC)
+		if err != nil {
+			return
+		}
+		defer unix.Close(fd)
+
+		attr := unix.MountAttr{
+			Attr_set:  unix.MOUNT_ATTR_IDMAP,
+			Userns_fd: uint64(^uintptr(0)), // deliberately invalid; we only care whether the attribute is recognized
+		}
+		err = unix.MountSetattr(fd, "", unix.AT_EMPTY_PATH, &attr)
+		idMapMountSupported = err != unix.EINVAL && err != unix.ENOSYS
+	})
+	return idMapMountSupported
+}
+
+// idMapMount applies MOUNT_ATTR_IDMAP to the detached mount held by f,
+// mapping it through a throwaway user namespace built from mapping.
+func idMapMount(ctx context.Context, f *os.File, mapping idtools.IdentityMapping) error {
+	usernsFD, cleanup, err := buildIDMapUsernsFD(ctx, mapping)
+	if err != nil {
+		return fmt.Errorf("building userns fd for idmapped mount: %w", err)
+	}
+	defer cleanup()
+
+	attr := unix.MountAttr{
+		Attr_set:  unix.MOUNT_ATTR_IDMAP,
+		Userns_fd: uint64(usernsFD),
+	}
+	if err := unix.MountSetattr(int(f.Fd()), "", unix.AT_EMPTY_PATH, &attr); err != nil {
+		return fmt.Errorf("mount_setattr(MOUNT_ATTR_IDMAP): %w", err)
+	}
+	return nil
+}
+
+// buildIDMapUsernsFD spawns a short-lived helper process that unshares a new
+// user namespace and writes uid_map/gid_map matching mapping, then returns an
+// fd for that namespace obtained via /proc/<pid>/ns/user. The helper is kept
+// alive (blocked on a pipe read) for as long as the returned fd is needed,
+// since the namespace is only kept alive while a process is a member of it
+// or it is bind-mounted/held open elsewhere.
+func buildIDMapUsernsFD(ctx context.Context, mapping idtools.IdentityMapping) (int, func(), error) {
+	cmd := reexec.Command(idMapMountHelperName)
+	cmd.Env = append(os.Environ(),
+		"DOCKER_IDMAP_UID_MAP="+idMapToProcMapText(mapping.UIDs()),
+		"DOCKER_IDMAP_GID_MAP="+idMapToProcMapText(mapping.GIDs()),
+	)
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return -1, nil, err
+	}
+	defer readyR.Close()
+	doneR, doneW, err := os.Pipe()
+	if err != nil {
+		readyW.Close()
+		return -1, nil, err
+	}
+	cmd.ExtraFiles = []*os.File{readyW, doneR}
+
+	if err := cmd.Start(); err != nil {
+		readyW.Close()
+		doneR.Close()
+		doneW.Close()
+		return -1, nil, err
+	}
+	readyW.Close()
+	doneR.Close()
+
+	// Wait for the helper to report that it has unshared and written its maps.
+	var buf [1]byte
+	if _, err := readyR.Read(buf[:]); err != nil {
+		doneW.Close()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return -1, nil, fmt.Errorf("waiting for idmap helper: %w", err)
+	}
+
+	usernsFD, err := unix.Open(fmt.Sprintf("/proc/%d/ns/user", cmd.Process.Pid), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		doneW.Close()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return -1, nil, fmt.Errorf("opening /proc/%d/ns/user: %w", cmd.Process.Pid, err)
+	}
+
+	cleanup := func() {
+		unix.Close(usernsFD)
+		doneW.Close()
+		if err := cmd.Wait(); err != nil {
+			log.G(ctx).WithError(err).Warn("idmap mount helper exited with error")
+		}
+	}
+
+	return usernsFD, cleanup, nil
+}
+
+// idMapMountHelperMain is the reexec entrypoint: it unshares a new user
+// namespace, writes the full subordinate uid_map/gid_map ranges from
+// mapping (not just the root pair) translating the daemon's remapped IDs
+// into the namespace, signals readiness on fd 3, then blocks until fd 4 is
+// closed by the parent.
+func idMapMountHelperMain() {
+	readyW := os.NewFile(3, "ready")
+	doneR := os.NewFile(4, "done")
+
+	if err := unix.Unshare(unix.CLONE_NEWUSER); err != nil {
+		os.Exit(1)
+	}
+
+	uidMap := os.Getenv("DOCKER_IDMAP_UID_MAP")
+	gidMap := os.Getenv("DOCKER_IDMAP_GID_MAP")
+	if err := os.WriteFile("/proc/self/setgroups", []byte("deny"), 0o644); err != nil {
+		os.Exit(1)
+	}
+	if err := os.WriteFile("/proc/self/uid_map", []byte(uidMap), 0o644); err != nil {
+		os.Exit(1)
+	}
+	if err := os.WriteFile("/proc/self/gid_map", []byte(gidMap), 0o644); err != nil {
+		os.Exit(1)
+	}
+
+	readyW.Write([]byte{1})
+	readyW.Close()
+
+	var buf [1]byte
+	doneR.Read(buf[:]) // returns on EOF once the parent closes its end
+	os.Exit(0)
+}
+
+// idMapToProcMapText renders idmaps as the text form /proc/<pid>/uid_map (or
+// gid_map) accepts: one "container-id host-id size" line per entry. Using
+// the full mapping here - rather than just mapping.RootPair()'s single
+// entry - means a host UID/GID outside the root pair (e.g. a directory
+// owned by a non-root user under the remapped range) still translates
+// correctly through the idmapped mount instead of falling outside it.
+func idMapToProcMapText(idmaps []idtools.IDMap) string {
+	lines := make([]string, 0, len(idmaps))
+	for _, m := range idmaps {
+		lines = append(lines, fmt.Sprintf("%d %d %d", m.ContainerID, m.HostID, m.Size))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !windows
+
+package daemon
+
+import (
+	"context"
+
+	mounttypes "github.com/moby/moby/api/types/mount"
+)
+
+// openTreeBindSource is only implemented on Linux, where open_tree(2) is
+// available. Other platforms always fall back to the existing path-based
+// bind-mount resolution.
+func (daemon *Daemon) openTreeBindSource(_ context.Context, _ string, _ *mounttypes.BindOptions) (procPath string, cleanup func(context.Context) error, ok bool, err error) {
+	return "", nil, false, nil
+}
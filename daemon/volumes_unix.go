@@ -69,6 +69,41 @@ func (daemon *Daemon) setupMounts(ctx context.Context, c *container.Container) (
 		}
 		mntCleanups.Add(clean)
 
+		// Bind-mount sources can live under host directories that are not
+		// traversable by the (possibly userns-remapped) container root, e.g.
+		// a restricted home directory in a rootless setup. When that's the
+		// case, resolve the source via open_tree(OPEN_TREE_CLONE) in the
+		// daemon's own user namespace instead, and hand the runtime a
+		// detached mount fd (as /proc/<pid>/fd/N) rather than the path. When
+		// userns-remap is active, the same detached mount is additionally
+		// idmapped (MOUNT_ATTR_IDMAP) so the host directory doesn't need to
+		// be pre-chowned for the remapped root. This only runs for mounts
+		// that actually need the detached tree - an idmapped mount, or a
+		// kernel-enforced recursive read-only mount - everything else keeps
+		// using the plain path from Setup() above. For an idmapped mount it
+		// is best-effort: it falls back to the path-based resolution above
+		// when open_tree is unavailable, and likewise (logging a warning)
+		// when it fails. A ReadOnlyForceRecursive mount that can't get the
+		// detached tree fails the container start instead.
+		forceRecursiveRO := m.Spec.BindOptions != nil && m.Spec.BindOptions.ReadOnlyForceRecursive
+		needsDetachedTree := m.Spec.Type == mounttypes.TypeBind &&
+			(daemon.shouldIDMapMount(m.Spec.BindOptions) || forceRecursiveRO)
+		if needsDetachedTree {
+			fdPath, fdClean, ok, err := daemon.openTreeBindSource(ctx, path, m.Spec.BindOptions)
+			switch {
+			case err != nil && m.Spec.BindOptions != nil && m.Spec.BindOptions.ReadOnlyForceRecursive:
+				// ReadOnlyForceRecursive is a guarantee, not a hint: fail the
+				// container start rather than silently falling back to a
+				// mount that the runtime may only enforce non-recursively.
+				return nil, nil, fmt.Errorf("mount %q: %w", m.Destination, err)
+			case err != nil:
+				log.G(ctx).WithError(err).WithField("source", path).Warn("failed to resolve bind mount source via open_tree, falling back to path-based mount")
+			case ok:
+				mntCleanups.Add(fdClean)
+				path = fdPath
+			}
+		}
+
 		if !c.TrySetNetworkMount(m.Destination, path) {
 			mnt := container.Mount{
 				Source:      path,
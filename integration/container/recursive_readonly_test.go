@@ -0,0 +1,62 @@
+//go:build !windows
+
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	containertypes "github.com/moby/moby/api/types/container"
+	mounttypes "github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/v2/integration/internal/container"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/skip"
+)
+
+// TestBindMountReadOnlyForceRecursiveNestedSubmount verifies that
+// ReadOnlyForceRecursive is a kernel-enforced guarantee: a tmpfs submount
+// created *inside* the bind-mounted source directory must also reject
+// writes from within the container, not just the top-level bind mount.
+func TestBindMountReadOnlyForceRecursiveNestedSubmount(t *testing.T) {
+	skip.If(t, testEnv.DaemonInfo.OSType != "linux", "ReadOnlyForceRecursive is Linux-only")
+	skip.If(t, testEnv.IsRootless, "mounting a tmpfs on the host requires root")
+	ctx := setupTest(t)
+	apiClient := testEnv.APIClient()
+
+	src := t.TempDir()
+	sub := filepath.Join(src, "sub")
+	assert.NilError(t, os.Mkdir(sub, 0o755))
+
+	// Mount an actual tmpfs at sub, on the host, before starting the
+	// container: a plain subdirectory of src would already come out
+	// read-only under a top-level (recursive or not) read-only bind mount,
+	// so it wouldn't exercise mount_setattr(AT_RECURSIVE) crossing into a
+	// separate mount at all. A real submount is what mount_setattr has to
+	// reach for the guarantee this test is named after to mean anything.
+	assert.NilError(t, unix.Mount("tmpfs", sub, "tmpfs", 0, ""))
+	defer func() {
+		assert.Check(t, unix.Unmount(sub, 0))
+	}()
+
+	ctrID := container.Run(ctx, t, apiClient,
+		container.WithMount(mounttypes.Mount{
+			Type:     mounttypes.TypeBind,
+			Source:   src,
+			Target:   "/data",
+			ReadOnly: true,
+			BindOptions: &mounttypes.BindOptions{
+				ReadOnlyForceRecursive: true,
+			},
+		}),
+		container.WithCmd("sleep", "infinity"),
+	)
+	defer container.Remove(ctx, t, apiClient, ctrID, containertypes.RemoveOptions{Force: true})
+
+	res, err := container.Exec(ctx, apiClient, ctrID, []string{"sh", "-c", "echo hi > /data/sub/file"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(res.ExitCode, 1))
+	assert.Check(t, is.Contains(res.Stderr(), "Read-only file system"))
+}
@@ -0,0 +1,112 @@
+// Package testdns provides a small DNS server, serving both UDP and TCP on
+// the same address, for integration tests that need to stand in for an
+// upstream resolver. Start more than one instance, each on its own loopback
+// address, to test conditional/per-domain forwarding: a resolver under test
+// that routes different query suffixes to different upstreams should reach
+// the matching instance's RespondAddr address.
+package testdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// HandlerFunc answers a single query, the same way dns.HandlerFunc does.
+type HandlerFunc func(w dns.ResponseWriter, query *dns.Msg)
+
+// Server is a test DNS server listening on one address for both UDP and
+// TCP, so tests can exercise a resolver's TCP fallback (e.g. for
+// truncated responses) as well as its usual UDP path.
+type Server struct {
+	udp *dns.Server
+	tcp *dns.Server
+}
+
+// Start starts a Server listening on addr:53 for both UDP and TCP,
+// dispatching every query received on either to handler.
+func Start(t *testing.T, addr string, handler HandlerFunc) *Server {
+	t.Helper()
+
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(addr), Port: 53})
+	assert.NilError(t, err)
+
+	tcpListener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP(addr), Port: 53})
+	assert.NilError(t, err)
+
+	s := &Server{
+		udp: &dns.Server{Handler: dns.HandlerFunc(handler), PacketConn: udpConn},
+		tcp: &dns.Server{Handler: dns.HandlerFunc(handler), Listener: tcpListener},
+	}
+	go func() { _ = s.udp.ActivateAndServe() }()
+	go func() { _ = s.tcp.ActivateAndServe() }()
+	return s
+}
+
+// Shutdown stops both the UDP and TCP listeners.
+func (s *Server) Shutdown() {
+	_ = s.udp.Shutdown()
+	_ = s.tcp.Shutdown()
+}
+
+// RespondPTR returns a HandlerFunc that answers PTR requests for any name
+// with ptr. It stands in for an upstream resolver in tests checking that a
+// PTR query the resolver under test can't answer itself (an address outside
+// any network it knows about) gets forwarded upstream, the same way
+// RespondAddr stands in for one answering A queries.
+func RespondPTR(ptr string) HandlerFunc {
+	return func(w dns.ResponseWriter, query *dns.Msg) {
+		if len(query.Question) == 0 || query.Question[0].Qtype != dns.TypePTR {
+			return
+		}
+
+		resp := &dns.Msg{}
+		resp.SetReply(query)
+		resp.Answer = append(resp.Answer, &dns.PTR{
+			Hdr: dns.RR_Header{
+				Name:   query.Question[0].Name,
+				Rrtype: dns.TypePTR,
+				Class:  dns.ClassINET,
+				Ttl:    600,
+			},
+			Ptr: dns.Fqdn(ptr),
+		})
+		_ = w.WriteMsg(resp)
+	}
+}
+
+// RespondAddr returns a HandlerFunc that answers type-A requests with addr.
+// A UDP response that would exceed 512 bytes (or the client's EDNS0 buffer
+// size, if advertised) is truncated with the TC bit set instead, so a
+// well-behaved client retries the same query over TCP.
+func RespondAddr(addr string) HandlerFunc {
+	return func(w dns.ResponseWriter, query *dns.Msg) {
+		if len(query.Question) == 0 || query.Question[0].Qtype != dns.TypeA {
+			return
+		}
+
+		resp := &dns.Msg{}
+		resp.SetReply(query)
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{
+				Name:   query.Question[0].Name,
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+				Ttl:    600,
+			},
+			A: net.ParseIP(addr),
+		})
+
+		if _, isUDP := w.RemoteAddr().(*net.UDPAddr); isUDP {
+			bufSize := dns.MinMsgSize
+			if opt := query.IsEdns0(); opt != nil {
+				bufSize = int(opt.UDPSize())
+			}
+			resp.Truncate(bufSize)
+		}
+
+		_ = w.WriteMsg(resp)
+	}
+}
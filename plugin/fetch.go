@@ -0,0 +1,190 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/core/leases"
+	"github.com/containerd/containerd/v2/core/remotes"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/v2/daemon/internal/distribution"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// DistributionConfig is the subset of daemon/images.ImageServiceConfig that
+// plugin distribution needs. It's deliberately shaped to match that struct
+// field-for-field so the daemon can hand plugin.NewFetcher the exact same
+// ContentStore/Leases/ContentNamespace it already threads into
+// images.NewImageService, rather than standing up a second, plugin-private
+// content store.
+type DistributionConfig struct {
+	ContentStore     content.Store
+	Leases           leases.Manager
+	ContentNamespace string
+	RegistryService  distribution.RegistryResolver
+}
+
+// Fetcher fetches and pushes OCI plugin artifacts using the same
+// containerd content store and lease manager the image service uses,
+// replacing the plugin subsystem's previous bespoke basicBlobStore. Blobs
+// shared between plugins and images (common base layers, repeated pulls of
+// the same digest) are deduplicated on disk and participate in the same
+// garbage collection, instead of living in a second, unrelated store.
+type Fetcher struct {
+	content content.Store
+	leases  leases.Manager
+	ns      string
+	resolve distribution.RegistryResolver
+}
+
+// NewFetcher builds a Fetcher from the same config shape plugin.Manager is
+// constructed with, mirroring daemon/images.NewImageService.
+func NewFetcher(cfg DistributionConfig) *Fetcher {
+	return &Fetcher{
+		content: cfg.ContentStore,
+		leases:  cfg.Leases,
+		ns:      cfg.ContentNamespace,
+		resolve: cfg.RegistryService,
+	}
+}
+
+// Pull resolves ref to a manifest via a containerd docker resolver and
+// fetches every descriptor in its manifest (config plus each layer) into
+// the shared content store, under a lease scoped to this pull so the blobs
+// survive until the caller has finished unpacking the plugin rootfs from
+// them.
+func (f *Fetcher) Pull(ctx context.Context, ref string) (ocispec.Descriptor, func(context.Context) error, error) {
+	resolver, err := f.resolve.ResolverForRef(ctx, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("plugin: resolving %s: %w", ref, err)
+	}
+
+	_, desc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("plugin: resolving %s: %w", ref, err)
+	}
+
+	fetcher, err := resolver.Fetcher(ctx, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+
+	l, err := f.leases.Create(ctx, leases.WithRandomID(), leases.WithExpiration(0))
+	if err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("plugin: creating lease for %s: %w", ref, err)
+	}
+	release := func(ctx context.Context) error {
+		return f.leases.Delete(ctx, l)
+	}
+	ctx = leases.WithLease(ctx, l.ID)
+
+	if err := f.fetchRecursive(ctx, fetcher, desc); err != nil {
+		release(ctx)
+		return ocispec.Descriptor{}, nil, err
+	}
+
+	return desc, release, nil
+}
+
+// fetchRecursive walks desc's manifest (if it has one) and ingests every
+// blob it references into f.content, skipping anything already present.
+func (f *Fetcher) fetchRecursive(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) error {
+	if err := f.fetchOne(ctx, fetcher, desc); err != nil {
+		return err
+	}
+
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, images.MediaTypeDockerSchema2Manifest:
+		manifest, err := images.Manifest(ctx, f.content, desc, nil)
+		if err != nil {
+			return err
+		}
+		children := append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...)
+		for _, child := range children {
+			if err := f.fetchOne(ctx, fetcher, child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *Fetcher) fetchOne(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) error {
+	if _, err := f.content.Info(ctx, desc.Digest); err == nil {
+		return nil
+	}
+
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return fmt.Errorf("plugin: fetching %s: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+
+	w, err := f.content.Writer(ctx, content.WithRef(desc.Digest.String()), content.WithDescriptor(desc))
+	if err != nil {
+		return fmt.Errorf("plugin: opening content writer for %s: %w", desc.Digest, err)
+	}
+	defer w.Close()
+
+	if err := content.Copy(ctx, w, rc, desc.Size, desc.Digest); err != nil {
+		return fmt.Errorf("plugin: ingesting %s: %w", desc.Digest, err)
+	}
+
+	return nil
+}
+
+// Push uploads desc and, if it's a manifest, everything it references, to
+// ref, resolving credentials/mirrors through the same RegistryService the
+// image service pushes through.
+func (f *Fetcher) Push(ctx context.Context, ref string, desc ocispec.Descriptor) error {
+	resolver, err := f.resolve.ResolverForRef(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("plugin: resolving push target %s: %w", ref, err)
+	}
+
+	pusher, err := resolver.Pusher(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	return f.pushRecursive(ctx, pusher, desc)
+}
+
+func (f *Fetcher) pushRecursive(ctx context.Context, pusher remotes.Pusher, desc ocispec.Descriptor) error {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, images.MediaTypeDockerSchema2Manifest:
+		manifest, err := images.Manifest(ctx, f.content, desc, nil)
+		if err != nil {
+			return err
+		}
+		for _, child := range append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...) {
+			if err := f.pushOne(ctx, pusher, child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return f.pushOne(ctx, pusher, desc)
+}
+
+func (f *Fetcher) pushOne(ctx context.Context, pusher remotes.Pusher, desc ocispec.Descriptor) error {
+	cw, err := pusher.Push(ctx, desc)
+	if err != nil {
+		if cerrdefs.IsAlreadyExists(err) {
+			return nil
+		}
+		return fmt.Errorf("plugin: pushing %s: %w", desc.Digest, err)
+	}
+	defer cw.Close()
+
+	ra, err := f.content.ReaderAt(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer ra.Close()
+
+	return content.Copy(ctx, cw, content.NewReader(ra), desc.Size, desc.Digest)
+}
